@@ -3,7 +3,8 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
+	"flag"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
@@ -13,67 +14,89 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sudo-JP/Load-Manager/backend/internal/config"
 	"github.com/sudo-JP/Load-Manager/backend/internal/database"
 	"github.com/sudo-JP/Load-Manager/backend/internal/repository"
 	"github.com/sudo-JP/Load-Manager/backend/internal/routes"
 	"github.com/sudo-JP/Load-Manager/backend/internal/server"
 	"github.com/sudo-JP/Load-Manager/backend/internal/service"
+	"github.com/sudo-JP/Load-Manager/common/certs"
+	"github.com/sudo-JP/Load-Manager/common/tracing"
 
 	// grpc
 	pbOrder "github.com/sudo-JP/Load-Manager/backend/api/proto/order"
 	pbProduct "github.com/sudo-JP/Load-Manager/backend/api/proto/product"
 	pbUser "github.com/sudo-JP/Load-Manager/backend/api/proto/user"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
-// host,port,error
-func parseCLI(args []string) (string, string, error) {
-	if len(args) < 5 {
-		return "", "", fmt.Errorf("not enough argument, missing --port and --host")
-	}
-	host := ""
-	port := ""
+type cliOptions struct {
+	host  string
+	port  string
+	level slog.Level
+	tls   certs.Files // gRPC TLS, off without a cert, CA requires client certificates
+}
 
-	isHost := false
-	isPort := false
+func parseCLI(args []string) (cliOptions, error) {
+	var opts cliOptions
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	fs.StringVar(&opts.host, "host", "", "gRPC host")
+	fs.StringVar(&opts.port, "port", "", "gRPC port")
+	logLevel := fs.String("log-level", "info", "Log level: debug, info, warn, error")
+	fs.StringVar(&opts.tls.Cert, "tls-cert", "", "Server certificate, enables TLS on gRPC")
+	fs.StringVar(&opts.tls.Key, "tls-key", "", "Key of --tls-cert")
+	fs.StringVar(&opts.tls.CA, "tls-client-ca", "", "CA of accepted clients, enables mutual TLS")
+	if err := fs.Parse(args[1:]); err != nil {
+		return opts, err
+	}
 
-	for i := 1; i < len(args); i++ {
-		if isHost && host != "" || isPort && port != "" {
-			return "", "", fmt.Errorf("invalid flag")
-		}
-		if isHost {
-			host = args[i]
-			isHost = false
-		} else if isPort {
-			port = args[i]
-			isPort = false
-		} else if args[i] == "--port" {
-			isPort = true
-		} else if args[i] == "--host" {
-			isHost = true
-		}
+	if opts.host == "" || opts.port == "" {
+		return opts, fmt.Errorf("not enough argument, missing --port and --host")
+	}
+	if err := opts.level.UnmarshalText([]byte(*logLevel)); err != nil {
+		return opts, fmt.Errorf("invalid log level %s", *logLevel)
 	}
-	return host, port, nil
+	if opts.tls.CA != "" && opts.tls.Cert == "" {
+		return opts, fmt.Errorf("--tls-client-ca needs --tls-cert and --tls-key")
+	}
+	return opts, nil
 }
 
 func main() {
 	// Port and host
 	// --port
 	// --host
-	host, port, err := parseCLI(os.Args)
+	// --log-level
+	// --tls-cert, --tls-key, --tls-client-ca
+	opts, err := parseCLI(os.Args)
 	if err != nil {
-		log.Fatalf("Failed to Parse Args: %v", err)
+		slog.Error("Failed to Parse Args", "error", err)
 		os.Exit(1)
 	}
+	host, port := opts.host, opts.port
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: opts.level})).
+		With("node", host+":"+port))
 
 	// Database
 	db, err := database.DatabaseConnection()
 	if err != nil {
-		log.Fatalf("Failed to connect to Database: %v", err)
+		slog.Error("Failed to connect to Database", "error", err)
 		os.Exit(2)
 	}
 	defer db.Close()
 
+	// Tracing
+	var exporter *tracing.FileExporter
+	if traceFile := config.TracingConfig(); traceFile != "" {
+		exporter, err = tracing.NewFileExporter(traceFile)
+		if err != nil {
+			slog.Error("Failed to open trace file", "error", err)
+			os.Exit(2)
+		}
+		tracing.SetTracer(tracing.NewTracer("backend:"+port, exporter))
+	}
+
 	// Repository
 	orderRepo := repository.NewOrderRepository(db)
 	userRepo := repository.NewUserRepository(db)
@@ -84,21 +107,37 @@ func main() {
 	productService := service.NewProductService(productRepo)
 	orderService := service.NewOrderService(orderRepo, userService, productService)
 
-	grpcServer := grpc.NewServer()
+	serverOpts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(
+		tracing.UnaryServerInterceptor(),
+		server.LoggingInterceptor(),
+	)}
+
+	// TLS, certificates are reloaded on change
+	if opts.tls.Cert != "" {
+		store, err := certs.NewStore(opts.tls, time.Second)
+		if err != nil {
+			slog.Error("Failed to load TLS certificates", "error", err)
+			os.Exit(2)
+		}
+		store.Start()
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(store.ServerConfig())))
+		slog.Info("gRPC TLS enabled", "mutual", opts.tls.CA != "")
+	}
+	grpcServer := grpc.NewServer(serverOpts...)
 	pbUser.RegisterUserServiceServer(grpcServer, server.NewUserServer(userService))
 	pbOrder.RegisterOrderServiceServer(grpcServer, server.NewOrderServer(orderService))
 	pbProduct.RegisterProductServiceServer(grpcServer, server.NewProductServer(productService))
 
 	tcpListener, err := net.Listen("tcp", host+":"+port)
 	if err != nil {
-		log.Fatalf("Failed to listen: %v", err)
+		slog.Error("Failed to listen", "error", err)
 		os.Exit(3)
 	}
 
-	log.Printf("gRPC server listening on :%s", port)
+	slog.Info("gRPC server listening", "port", port)
 	go func() {
 		if err := grpcServer.Serve(tcpListener); err != nil {
-			log.Fatalf("Failed to serve: %v", err)
+			slog.Error("Failed to serve", "error", err)
 			os.Exit(4)
 		}
 	}()
@@ -106,15 +145,15 @@ func main() {
 	id, err := strconv.Atoi(port)
 	id = id - 50000
 	if err != nil {
-		log.Fatalf("Failed to serve: %v", err)
+		slog.Error("Failed to serve", "error", err)
 		os.Exit(5)
 	}
 	// HTTP Server (for direct testing and comparison)
 	httpServer := startHTTPServer(userService, productService, orderService, id)
-	log.Printf("HTTP server listening on " + strconv.Itoa(9000 + id))
+	slog.Info("HTTP server listening", "port", 9000 + id)
 	go func() {
 		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start HTTP server: %v", err)
+			slog.Error("Failed to start HTTP server", "error", err)
 			os.Exit(5)
 		}
 	}()
@@ -124,16 +163,23 @@ func main() {
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	<-sigCh
 
-	log.Println("Shutting down...")
+	slog.Info("Shutting down...")
 
 	// Shutdown HTTP server
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := httpServer.Shutdown(ctx); err != nil {
-		log.Printf("HTTP server shutdown error: %v", err)
+		slog.Error("HTTP server shutdown error", "error", err)
 	}
 
 	grpcServer.GracefulStop()
+
+	// Flush spans, os.Exit skips defers
+	if exporter != nil {
+		if err := exporter.Close(); err != nil {
+			slog.Error("Trace exporter close error", "error", err)
+		}
+	}
 	os.Exit(0)
 }
 
@@ -36,3 +36,9 @@ func DatabaseConfig() (string, error) {
 
     return url, nil
 }
+
+// TracingConfig returns the JSON lines span file, tracing is off when TRACE_FILE is unset.
+// Call after DatabaseConfig so .env is loaded
+func TracingConfig() string {
+    return os.Getenv("TRACE_FILE")
+}
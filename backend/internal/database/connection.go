@@ -20,7 +20,13 @@ func DatabaseConnection() (*Database, error) {
         return nil, err
     }
 
-    conn, err := pgxpool.New(context.Background(), URL)
+    poolConf, err := pgxpool.ParseConfig(URL)
+    if err != nil {
+        return nil, err
+    }
+    poolConf.ConnConfig.Tracer = sqlTracer{}
+
+    conn, err := pgxpool.NewWithConfig(context.Background(), poolConf)
     if err != nil {
         return nil, err
     }
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sudo-JP/Load-Manager/common/tracing"
+)
+
+type sqlSpanKey struct{}
+
+// sqlTracer records a span per SQL statement, only inside an existing trace
+// so migrations and other background queries stay out of the span file
+type sqlTracer struct{}
+
+func startSQLSpan(ctx context.Context, name string, attrs ...tracing.StartOption) context.Context {
+	if !tracing.SpanFromContext(ctx).Context().IsValid() {
+		return ctx
+	}
+	_, span := tracing.Start(ctx, name, attrs...)
+	return context.WithValue(ctx, sqlSpanKey{}, span)
+}
+
+func endSQLSpan(ctx context.Context, err error) {
+	span, ok := ctx.Value(sqlSpanKey{}).(*tracing.Span)
+	if !ok {
+		return
+	}
+	span.SetError(err)
+	span.End()
+}
+
+func (sqlTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn,
+	data pgx.TraceQueryStartData) context.Context {
+	return startSQLSpan(ctx, "sql.query", tracing.WithAttr("db.statement", data.SQL))
+}
+
+func (sqlTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	endSQLSpan(ctx, data.Err)
+}
+
+func (sqlTracer) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn,
+	data pgx.TraceCopyFromStartData) context.Context {
+	return startSQLSpan(ctx, "sql.copy_from",
+		tracing.WithAttr("db.table", data.TableName.Sanitize()))
+}
+
+func (sqlTracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	endSQLSpan(ctx, data.Err)
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Load manager job ids, one value per job in the batch
+const correlationIDKey = "x-correlation-id"
+
+func correlationIDs(ctx context.Context) []string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+	return md.Get(correlationIDKey)
+}
+
+// LoggingInterceptor logs every call with the job ids it carries
+func LoggingInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		logger := slog.With(
+			"method", info.FullMethod,
+			"correlation_ids", correlationIDs(ctx),
+			"duration_ms", float64(time.Since(start).Microseconds())/1000,
+		)
+		if err != nil {
+			logger.Error("gRPC call failed", "error", err)
+		} else {
+			logger.Debug("gRPC call")
+		}
+		return resp, err
+	}
+}
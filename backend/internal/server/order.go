@@ -5,6 +5,7 @@ import (
 
 	pb "github.com/sudo-JP/Load-Manager/backend/api/proto/order"
 	"github.com/sudo-JP/Load-Manager/backend/internal/service"
+	"github.com/sudo-JP/Load-Manager/common/tracing"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
@@ -15,21 +16,29 @@ type OrderServer struct {
 
 func (s *OrderServer) CreateOrders(ctx context.Context, 
 	req *pb.CreateOrdersRequest) (*emptypb.Empty, error) {
+	ctx, span := tracing.Start(ctx, "service.CreateOrders")
+	defer span.End()
 	return s.svc.ProtoCreateOrders(ctx, req)
 }
 
 func (s *OrderServer) GetOrders(ctx context.Context,
 	req *pb.GetOrdersRequest) (*pb.GetOrdersResponse, error) {
+	ctx, span := tracing.Start(ctx, "service.GetOrders")
+	defer span.End()
 	return s.svc.ProtoGetOrders(ctx, req)
 }
 
 func (s *OrderServer) UpdateOrders(ctx context.Context,
 	req *pb.UpdateOrdersRequest) (*emptypb.Empty, error) {
+	ctx, span := tracing.Start(ctx, "service.UpdateOrders")
+	defer span.End()
 	return s.svc.ProtoUpdateOrders(ctx, req)
 }
 
 func (s *OrderServer) DeleteOrders(ctx context.Context,
 	req *pb.DeleteOrdersRequest) (*emptypb.Empty, error) {
+	ctx, span := tracing.Start(ctx, "service.DeleteOrders")
+	defer span.End()
 	return s.svc.ProtoDeleteOrders(ctx, req)
 }
 
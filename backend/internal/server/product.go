@@ -5,6 +5,7 @@ import (
 
 	pb "github.com/sudo-JP/Load-Manager/backend/api/proto/product"
 	"github.com/sudo-JP/Load-Manager/backend/internal/service"
+	"github.com/sudo-JP/Load-Manager/common/tracing"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
@@ -15,21 +16,29 @@ type ProductServer struct {
 
 func (s *ProductServer) CreateProducts(ctx context.Context, 
 	req *pb.CreateProductsRequest) (*emptypb.Empty, error) {
+	ctx, span := tracing.Start(ctx, "service.CreateProducts")
+	defer span.End()
 	return s.svc.ProtoCreateProducts(ctx, req)
 }
 
 func (s *ProductServer) GetProducts(ctx context.Context,
 	req *pb.GetProductsRequest) (*pb.GetProductsResponse, error) {
+	ctx, span := tracing.Start(ctx, "service.GetProducts")
+	defer span.End()
 	return s.svc.ProtoGetProducts(ctx, req)
 }
 
 func (s *ProductServer) UpdateProducts(ctx context.Context,
 	req *pb.UpdateProductsRequest) (*emptypb.Empty, error) {
+	ctx, span := tracing.Start(ctx, "service.UpdateProducts")
+	defer span.End()
 	return s.svc.ProtoUpdateProducts(ctx, req)
 }
 
 func (s *ProductServer) DeleteProducts(ctx context.Context,
 	req *pb.DeleteProductsRequest) (*emptypb.Empty, error) {
+	ctx, span := tracing.Start(ctx, "service.DeleteProducts")
+	defer span.End()
 	return s.svc.ProtoDeleteProducts(ctx, req)
 }
 
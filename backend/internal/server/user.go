@@ -5,6 +5,7 @@ import (
 
 	pb "github.com/sudo-JP/Load-Manager/backend/api/proto/user"
 	"github.com/sudo-JP/Load-Manager/backend/internal/service"
+	"github.com/sudo-JP/Load-Manager/common/tracing"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
@@ -15,21 +16,29 @@ type UserServer struct {
 
 func (s *UserServer) CreateUsers(ctx context.Context, 
 	req *pb.CreateUsersRequest) (*emptypb.Empty, error) {
+	ctx, span := tracing.Start(ctx, "service.CreateUsers")
+	defer span.End()
 	return s.svc.ProtoCreateUsers(ctx, req)
 }
 
 func (s *UserServer) GetUsers(ctx context.Context,
 	req *pb.GetUsersRequest) (*pb.GetUsersResponse, error) {
+	ctx, span := tracing.Start(ctx, "service.GetUsers")
+	defer span.End()
 	return s.svc.ProtoGetUsers(ctx, req)
 }
 
 func (s *UserServer) UpdateUsers(ctx context.Context,
 	req *pb.UpdateUsersRequest) (*emptypb.Empty, error) {
+	ctx, span := tracing.Start(ctx, "service.UpdateUsers")
+	defer span.End()
 	return s.svc.ProtoUpdateUsers(ctx, req)
 }
 
 func (s *UserServer) DeleteUsers(ctx context.Context,
 	req *pb.DeleteUsersRequest) (*emptypb.Empty, error) {
+	ctx, span := tracing.Start(ctx, "service.DeleteUsers")
+	defer span.End()
 	return s.svc.ProtoDeleteUsers(ctx, req)
 }
 
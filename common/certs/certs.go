@@ -0,0 +1,159 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sudo-JP/Load-Manager/common/filewatch"
+)
+
+// PEM files, Cert and Key are our own identity. CA verifies the peer: the
+// server for a client, clients for a server, which then requires them
+type Files struct {
+	CA   string
+	Cert string
+	Key  string
+}
+
+/*
+Store holds a certificate and CA pool loaded from Files. The tls.Configs it
+hands out read the current ones on every handshake, so a reload applies to
+new connections without a restart, open connections keep their certificates
+*/
+type Store struct {
+	files   Files
+	watcher *filewatch.Watcher
+
+	mutex sync.RWMutex
+	cert  *tls.Certificate // nil without Cert and Key
+	pool  *x509.CertPool   // nil without CA, clients use the system roots, servers check no clients
+}
+
+// Reload reads the files again, a bad file keeps the previous certificates
+func (s *Store) Reload() error {
+	return s.watcher.Reload()
+}
+
+func (s *Store) load(pems [][]byte) error {
+	caPEM, certPEM, keyPEM := pems[0], pems[1], pems[2]
+
+	var cert *tls.Certificate
+	if certPEM != nil {
+		pair, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.files.Cert, err)
+		}
+		cert = &pair
+	}
+
+	var pool *x509.CertPool
+	if caPEM != nil {
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return fmt.Errorf("no certificates in %s", s.files.CA)
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.cert = cert
+	s.pool = pool
+	return nil
+}
+
+func (s *Store) current() (*tls.Certificate, *x509.CertPool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.cert, s.pool
+}
+
+func verify(certs []*x509.Certificate, pool *x509.CertPool, name string, usage x509.ExtKeyUsage) error {
+	if len(certs) == 0 {
+		return errors.New("no peer certificate")
+	}
+	opts := x509.VerifyOptions{
+		Roots:         pool,
+		DNSName:       name,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(opts)
+	return err
+}
+
+/*
+ClientConfig verifies the server against the CA and presents the certificate,
+if any, for mutual TLS. An empty serverName checks the host dialed.
+Go's own verification is off so that a reloaded CA is used,
+VerifyConnection does the same checks
+*/
+func (s *Store) ClientConfig(serverName string) *tls.Config {
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			_, pool := s.current()
+			return verify(cs.PeerCertificates, pool, cs.ServerName, x509.ExtKeyUsageServerAuth)
+		},
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			if cert, _ := s.current(); cert != nil {
+				return cert, nil
+			}
+			return &tls.Certificate{}, nil // none, the server decides
+		},
+	}
+}
+
+// ServerConfig presents the certificate, with a CA it also requires
+// client certificates signed by it
+func (s *Store) ServerConfig() *tls.Config {
+	conf := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert, _ := s.current()
+			if cert == nil {
+				return nil, errors.New("no server certificate")
+			}
+			return cert, nil
+		},
+	}
+	if s.files.CA != "" {
+		// Checked in VerifyConnection against the current CA
+		conf.ClientAuth = tls.RequireAnyClientCert
+		conf.VerifyConnection = func(cs tls.ConnectionState) error {
+			_, pool := s.current()
+			return verify(cs.PeerCertificates, pool, "", x509.ExtKeyUsageClientAuth)
+		}
+	}
+	return conf
+}
+
+// Start checks the files for changes every interval
+func (s *Store) Start() {
+	s.watcher.Start()
+}
+
+func (s *Store) Stop() {
+	s.watcher.Stop()
+}
+
+// NewStore loads the files, Cert and Key go together
+func NewStore(files Files, interval time.Duration) (*Store, error) {
+	if (files.Cert == "") != (files.Key == "") {
+		return nil, errors.New("cert and key must be given together")
+	}
+	s := &Store{files: files}
+	s.watcher = filewatch.New("TLS certificates", []string{files.CA, files.Cert, files.Key}, interval, s.load)
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
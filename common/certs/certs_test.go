@@ -0,0 +1,119 @@
+package certs
+
+import (
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testCA(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := GenerateTestCA(dir, "localhost", "127.0.0.1"); err != nil {
+		t.Fatalf("GenerateTestCA: %v", err)
+	}
+	return dir
+}
+
+func store(t *testing.T, files Files) *Store {
+	t.Helper()
+	s, err := NewStore(files, time.Hour)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	return s
+}
+
+// handshake serves one TLS connection and reports the client's side
+func handshake(t *testing.T, server, client *tls.Config) error {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", server)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.(*tls.Conn).Handshake()
+		_, _ = conn.Read(make([]byte, 1)) // until the client hangs up
+	}()
+
+	conn, err := tls.Dial("tcp", ln.Addr().String(), client)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// TLS 1.3 reports a rejected client certificate on the first read
+	_ = conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if _, err := conn.Read(make([]byte, 1)); err != nil && !os.IsTimeout(err) {
+		return err
+	}
+	return nil
+}
+
+func TestStore_MutualTLS(t *testing.T) {
+	dir := testCA(t)
+	ca := filepath.Join(dir, TestCAFile)
+	server := store(t, Files{CA: ca,
+		Cert: filepath.Join(dir, TestServerCert), Key: filepath.Join(dir, TestServerKey)})
+	client := store(t, Files{CA: ca,
+		Cert: filepath.Join(dir, TestClientCert), Key: filepath.Join(dir, TestClientKey)})
+
+	if err := handshake(t, server.ServerConfig(), client.ClientConfig("localhost")); err != nil {
+		t.Fatalf("Expected handshake to succeed, got %v", err)
+	}
+
+	anonymous := store(t, Files{CA: ca})
+	if err := handshake(t, server.ServerConfig(), anonymous.ClientConfig("localhost")); err == nil {
+		t.Errorf("Expected server to reject a client without a certificate")
+	}
+
+	if err := handshake(t, server.ServerConfig(), client.ClientConfig("other.host")); err == nil {
+		t.Errorf("Expected client to reject a certificate for another host")
+	}
+}
+
+func TestStore_Reload(t *testing.T) {
+	dir := testCA(t)
+	files := Files{CA: filepath.Join(dir, TestCAFile),
+		Cert: filepath.Join(dir, TestServerCert), Key: filepath.Join(dir, TestServerKey)}
+	server := store(t, files)
+	client := store(t, Files{CA: files.CA,
+		Cert: filepath.Join(dir, TestClientCert), Key: filepath.Join(dir, TestClientKey)})
+
+	// Rotate the server to a new CA, the old client no longer trusts it
+	other := testCA(t)
+	for _, name := range []string{TestCAFile, TestServerCert, TestServerKey} {
+		data, err := os.ReadFile(filepath.Join(other, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := server.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if err := handshake(t, server.ServerConfig(), client.ClientConfig("localhost")); err == nil {
+		t.Errorf("Expected handshake to fail after the server moved to a new CA")
+	}
+
+	// A broken file keeps the loaded certificates
+	if err := os.WriteFile(files.Cert, []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Reload(); err == nil {
+		t.Errorf("Expected Reload to fail on a broken certificate")
+	}
+	if cert, _ := server.current(); cert == nil {
+		t.Errorf("Expected previous certificate to be kept")
+	}
+}
@@ -0,0 +1,121 @@
+package filewatch
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+Watcher polls a set of files every interval and hands their contents to
+apply when any of them changed. Empty paths read as nil. When apply fails
+the files count as unchanged content not yet applied, so the next poll
+tries again and whatever apply loaded before stays in use
+*/
+type Watcher struct {
+	name     string // what the files hold, for logs
+	paths    []string
+	interval time.Duration
+	apply    func(data [][]byte) error
+	stopCh   chan struct{}
+	touched  atomic.Bool
+
+	mutex sync.Mutex
+	last  [][]byte // nil until apply first succeeds
+}
+
+func readFiles(paths []string) ([][]byte, error) {
+	data := make([][]byte, len(paths))
+	for i, path := range paths {
+		if path == "" {
+			continue
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		data[i] = b
+	}
+	return data, nil
+}
+
+func equal(a, b [][]byte) bool {
+	if a == nil || len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Reload reads the files and applies them if they changed or Touch was called
+func (w *Watcher) Reload() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	data, err := readFiles(w.paths)
+	if err != nil {
+		return err
+	}
+	touched := w.touched.Swap(false)
+	if equal(w.last, data) && !touched {
+		return nil
+	}
+
+	if err := w.apply(data); err != nil {
+		return err
+	}
+	if w.last != nil && !equal(w.last, data) {
+		slog.Info("Reloaded "+w.name, "files", w.paths)
+	}
+	w.last = data
+	return nil
+}
+
+// Touch makes the next Reload apply the files even if they did not change,
+// it is safe to call from apply
+func (w *Watcher) Touch() {
+	w.touched.Store(true)
+}
+
+func (w *Watcher) run() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.Reload(); err != nil {
+				slog.Error("Failed to reload "+w.name, "files", w.paths, "error", err)
+			}
+		case <-w.stopCh:
+			return
+		}
+	}
+}
+
+// Start checks the files for changes every interval
+func (w *Watcher) Start() {
+	go w.run()
+}
+
+func (w *Watcher) Stop() {
+	close(w.stopCh)
+}
+
+// New does not read the files, call Reload for the first load
+func New(name string, paths []string, interval time.Duration, apply func(data [][]byte) error) *Watcher {
+	return &Watcher{
+		name:     name,
+		paths:    paths,
+		interval: interval,
+		apply:    apply,
+		stopCh:   make(chan struct{}),
+	}
+}
@@ -0,0 +1,62 @@
+package filewatch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	os.WriteFile(path, []byte("a"), 0o600)
+
+	var applied []string
+	fail := false
+	w := New("test file", []string{path, ""}, time.Hour, func(data [][]byte) error {
+		if data[1] != nil {
+			t.Errorf("Expected an empty path to read as nil")
+		}
+		if fail {
+			return errors.New("bad file")
+		}
+		applied = append(applied, string(data[0]))
+		return nil
+	})
+
+	steps := []struct {
+		content string
+		touch   bool
+		fail    bool
+		want    int // applies so far
+	}{
+		{"a", false, false, 1},
+		{"a", false, false, 1}, // unchanged
+		{"a", true, false, 2},  // touched
+		{"b", false, true, 2},  // fails, "a" stays applied
+		{"b", false, false, 3}, // retried on the next poll
+	}
+	for i, step := range steps {
+		os.WriteFile(path, []byte(step.content), 0o600)
+		if step.touch {
+			w.Touch()
+		}
+		fail = step.fail
+		err := w.Reload()
+		if (err != nil) != step.fail {
+			t.Errorf("Step %d: unexpected error %v", i, err)
+		}
+		if len(applied) != step.want {
+			t.Errorf("Step %d: expected %d applies, got %v", i, step.want, applied)
+		}
+	}
+	if applied[2] != "b" {
+		t.Errorf("Expected the new content applied, got %v", applied)
+	}
+
+	os.Remove(path)
+	if err := w.Reload(); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+}
@@ -0,0 +1,120 @@
+package tracing
+
+import (
+	"bufio"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Link struct {
+	TraceID string `json:"trace_id"`
+	SpanID  string `json:"span_id"`
+}
+
+type SpanData struct {
+	TraceID      string         `json:"trace_id"`
+	SpanID       string         `json:"span_id"`
+	ParentSpanID string         `json:"parent_span_id,omitempty"`
+	Name         string         `json:"name"`
+	Service      string         `json:"service"`
+	Start        time.Time      `json:"start"`
+	End          time.Time      `json:"end"`
+	DurationMs   float64        `json:"duration_ms"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+	Links        []Link         `json:"links,omitempty"`
+	Error        string         `json:"error,omitempty"`
+}
+
+type Exporter interface {
+	Export(span SpanData)
+}
+
+/*
+FileExporter appends one JSON span per line, works offline and is easy to
+load from the Python harness. Export never blocks, spans are dropped when
+the buffer is full.
+*/
+type FileExporter struct {
+	file    *os.File
+	spans   chan SpanData
+	done    chan struct{}
+	dropped atomic.Int64
+	closed  bool
+	mutex   sync.RWMutex // guards closed, spans may still end after Close
+}
+
+func (e *FileExporter) Export(span SpanData) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	if e.closed {
+		e.dropped.Add(1)
+		return
+	}
+
+	select {
+	case e.spans <- span:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+func (e *FileExporter) run() {
+	defer close(e.done)
+
+	writer := bufio.NewWriter(e.file)
+	encoder := json.NewEncoder(writer)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case span, ok := <-e.spans:
+			if !ok {
+				if err := writer.Flush(); err != nil {
+					slog.Error("Failed to flush spans", "error", err)
+				}
+				return
+			}
+			if err := encoder.Encode(span); err != nil {
+				slog.Error("Failed to write span", "error", err)
+			}
+		case <-ticker.C:
+			if err := writer.Flush(); err != nil {
+				slog.Error("Failed to flush spans", "error", err)
+			}
+		}
+	}
+}
+
+// Close flushes buffered spans, later spans are dropped
+func (e *FileExporter) Close() error {
+	e.mutex.Lock()
+	e.closed = true
+	close(e.spans)
+	e.mutex.Unlock()
+
+	<-e.done
+	if dropped := e.dropped.Load(); dropped > 0 {
+		slog.Warn("Dropped spans, exporter buffer full", "dropped", dropped)
+	}
+	return e.file.Close()
+}
+
+func NewFileExporter(path string) (*FileExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &FileExporter{
+		file:  file,
+		spans: make(chan SpanData, 8192),
+		done:  make(chan struct{}),
+	}
+	go e.run()
+	return e, nil
+}
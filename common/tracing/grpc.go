@@ -0,0 +1,57 @@
+package tracing
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const traceParentKey = "traceparent"
+
+// Inject adds the span in ctx to outgoing gRPC metadata
+func Inject(ctx context.Context) context.Context {
+	sc := SpanFromContext(ctx).Context()
+	if !sc.IsValid() {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, traceParentKey, sc.TraceParent())
+}
+
+// Extract reads the remote parent from incoming gRPC metadata
+func Extract(ctx context.Context) (SpanContext, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return SpanContext{}, false
+	}
+	values := md.Get(traceParentKey)
+	if len(values) == 0 {
+		return SpanContext{}, false
+	}
+	return ParseTraceParent(values[0])
+}
+
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any,
+		cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(Inject(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// UnaryServerInterceptor starts a server span under the caller's span
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (any, error) {
+		var opts []StartOption
+		if parent, ok := Extract(ctx); ok {
+			opts = append(opts, WithParent(parent))
+		}
+
+		ctx, span := Start(ctx, "grpc.server", append(opts, WithAttr("rpc.method", info.FullMethod))...)
+		defer span.End()
+
+		resp, err := handler(ctx, req)
+		span.SetError(err)
+		return resp, err
+	}
+}
@@ -0,0 +1,256 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Minimal OpenTelemetry style tracing. Spans are sent to an Exporter
+when they end, trace context crosses process boundaries as a W3C
+traceparent header in gRPC metadata.
+*/
+
+type TraceID [16]byte
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// W3C traceparent, always sampled
+func (sc SpanContext) TraceParent() string {
+	return fmt.Sprintf("00-%s-%s-01", sc.TraceID, sc.SpanID)
+}
+
+func ParseTraceParent(header string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(header, "-")
+	if len(parts) != 4 || parts[0] != "00" {
+		return sc, false
+	}
+
+	trace, err := hex.DecodeString(parts[1])
+	if err != nil || len(trace) != len(sc.TraceID) {
+		return sc, false
+	}
+	span, err := hex.DecodeString(parts[2])
+	if err != nil || len(span) != len(sc.SpanID) {
+		return sc, false
+	}
+
+	copy(sc.TraceID[:], trace)
+	copy(sc.SpanID[:], span)
+	return sc, sc.IsValid()
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for i := range id {
+		id[i] = byte(rand.IntN(256))
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for i := range id {
+		id[i] = byte(rand.IntN(256))
+	}
+	return id
+}
+
+type Span struct {
+	tracer *Tracer
+	name   string
+	sc     SpanContext
+	parent SpanID
+	start  time.Time
+	attrs  map[string]any
+	links  []SpanContext
+	err    string
+	ended  bool
+	mutex  sync.Mutex
+}
+
+// Context is invalid when tracing is disabled, so nothing gets propagated
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil || s.tracer == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.attrs[key] = value
+}
+
+func (s *Span) AddLink(sc SpanContext) {
+	if s == nil || s.tracer == nil || !sc.IsValid() {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.links = append(s.links, sc)
+}
+
+func (s *Span) SetError(err error) {
+	if s == nil || s.tracer == nil || err == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.err = err.Error()
+}
+
+func (s *Span) End() {
+	s.EndAt(time.Now())
+}
+
+func (s *Span) EndAt(end time.Time) {
+	if s == nil || s.tracer == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	data := SpanData{
+		TraceID:    s.sc.TraceID.String(),
+		SpanID:     s.sc.SpanID.String(),
+		Name:       s.name,
+		Service:    s.tracer.service,
+		Start:      s.start,
+		End:        end,
+		DurationMs: float64(end.Sub(s.start).Microseconds()) / 1000,
+		Attributes: s.attrs,
+		Error:      s.err,
+	}
+	if s.parent != (SpanID{}) {
+		data.ParentSpanID = s.parent.String()
+	}
+	for _, link := range s.links {
+		data.Links = append(data.Links, Link{
+			TraceID: link.TraceID.String(),
+			SpanID:  link.SpanID.String(),
+		})
+	}
+	s.mutex.Unlock()
+
+	s.tracer.exporter.Export(data)
+}
+
+type spanKey struct{}
+
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+type startConfig struct {
+	start  time.Time
+	parent SpanContext
+	attrs  map[string]any
+}
+
+type StartOption func(*startConfig)
+
+// WithStart backdates a span, e.g. time in queue starts at Job.CreatedAt
+func WithStart(t time.Time) StartOption {
+	return func(c *startConfig) { c.start = t }
+}
+
+// WithParent overrides the parent found in the context
+func WithParent(sc SpanContext) StartOption {
+	return func(c *startConfig) { c.parent = sc }
+}
+
+func WithAttr(key string, value any) StartOption {
+	return func(c *startConfig) { c.attrs[key] = value }
+}
+
+type Tracer struct {
+	service  string
+	exporter Exporter
+}
+
+func (t *Tracer) Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	if t == nil || t.exporter == nil {
+		return ctx, nil
+	}
+
+	conf := startConfig{
+		start:  time.Now(),
+		parent: SpanFromContext(ctx).Context(),
+		attrs:  make(map[string]any),
+	}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	span := &Span{
+		tracer: t,
+		name:   name,
+		start:  conf.start,
+		attrs:  conf.attrs,
+		sc:     SpanContext{TraceID: conf.parent.TraceID, SpanID: newSpanID()},
+	}
+	if conf.parent.IsValid() {
+		span.parent = conf.parent.SpanID
+	} else {
+		span.sc.TraceID = newTraceID()
+	}
+
+	return ContextWithSpan(ctx, span), span
+}
+
+func NewTracer(service string, exporter Exporter) *Tracer {
+	return &Tracer{
+		service:  service,
+		exporter: exporter,
+	}
+}
+
+// Global tracer, nil means tracing is off
+var (
+	global      *Tracer
+	globalMutex sync.RWMutex
+)
+
+func SetTracer(t *Tracer) {
+	globalMutex.Lock()
+	defer globalMutex.Unlock()
+	global = t
+}
+
+func Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	globalMutex.RLock()
+	t := global
+	globalMutex.RUnlock()
+	return t.Start(ctx, name, opts...)
+}
@@ -0,0 +1,59 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+)
+
+type memExporter struct {
+	spans []SpanData
+}
+
+func (m *memExporter) Export(span SpanData) {
+	m.spans = append(m.spans, span)
+}
+
+func TestTraceParent_RoundTrip(t *testing.T) {
+	sc := SpanContext{TraceID: newTraceID(), SpanID: newSpanID()}
+
+	parsed, ok := ParseTraceParent(sc.TraceParent())
+	if !ok || parsed != sc {
+		t.Errorf("Expected %v, got %v", sc, parsed)
+	}
+
+	for _, bad := range []string{"", "00-abc-def-01", "01-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-01"} {
+		if _, ok := ParseTraceParent(bad); ok {
+			t.Errorf("Invalid traceparent %q accepted", bad)
+		}
+	}
+}
+
+func TestTracer_ParentChild(t *testing.T) {
+	exporter := &memExporter{}
+	tracer := NewTracer("test", exporter)
+
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, child := tracer.Start(ctx, "child")
+	child.End()
+	root.End()
+
+	if len(exporter.spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(exporter.spans))
+	}
+	childData, rootData := exporter.spans[0], exporter.spans[1]
+	if childData.TraceID != rootData.TraceID {
+		t.Errorf("Child must share the root trace id")
+	}
+	if childData.ParentSpanID != rootData.SpanID {
+		t.Errorf("Expected parent %s, got %s", rootData.SpanID, childData.ParentSpanID)
+	}
+
+	// Disabled tracer hands out nil spans that are safe to use
+	var off *Tracer
+	_, span := off.Start(context.Background(), "noop")
+	span.SetAttr("k", "v")
+	span.End()
+	if span.Context().IsValid() {
+		t.Errorf("Disabled tracer must not produce a valid span context")
+	}
+}
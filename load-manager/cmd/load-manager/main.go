@@ -3,33 +3,58 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
-	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/sudo-JP/Load-Manager/common/certs"
+	"github.com/sudo-JP/Load-Manager/common/tracing"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/admission"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/auth"
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/batcher"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/breaker"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/cache"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/concurrency"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/config"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/discovery"
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/grpc"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/metrics"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/proxy"
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue/algorithms"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/ratelimit"
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/registry"
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/routes"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/scheduler"
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/selector"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/server"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/strategy"
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/worker"
+	ggrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
-// CLI
+// CLI, flags override the config file, see internal/config
 var (
-	addresses []string // nodes addrs
-	queueType string
-	sel       string
-	loadStrat string
+	configPath string
+	mode       string
+
+	addresses   []string // nodes addrs
+	nodesFile   string   // alternative to addresses, watched for changes
+	nodesReload int
+	queueType   string
+	sel         string
+	loadStrat   string
+	port        int
+	grpcPort    int
 
 	// Batch
 	batSize    int
@@ -37,196 +62,599 @@ var (
 
 	// Workers
 	numWorkers int
+
+	// Circuit breaker
+	breakerFailures int
+	breakerTimeout  int
+	breakerProbes   int
+
+	// Timeouts
+	requestTimeout  int
+	drainTimeout    int
+	shutdownTimeout int
+
+	// Retry
+	retryAttempts int
+	retryBackoff  int
+
+	// Ramp up window for new and recovered nodes
+	slowStart int
+
+	// JSON lines span file, empty disables tracing
+	traceFile string
+
+	// debug, info, warn, error
+	logLevel string
+
+	// HTTPS and API keys
+	tlsCert string
+	tlsKey  string
+	apiKeys string
+
+	// Per client rate limit
+	rateLimit float64
+	rateBurst int
+
+	// Adaptive concurrency limit per node
+	concurrencyAlgo string
+	concurrencyMax  int
+
+	// Hedged reads
+	hedgePercentile float64
+
+	// Collapse superseded writes in a batch
+	coalesceWrites bool
+
+	// Adaptive batch size and timeout
+	batchAdaptive bool
+
+	// Flush on payload bytes or the oldest job's age
+	batchMaxBytes int
+	batchMaxAge   int
+
+	// Read cache
+	cacheSize int
+	cacheTTL  int
+
+	// CoDel admission control
+	admissionTarget   int
+	admissionInterval int
+
+	// TLS to the backends
+	backendCA         string
+	backendCert       string
+	backendKey        string
+	backendServerName string
 )
 
+// Config field set by each flag, applied only when the flag is given
+var flagOverrides = map[string]func(c *config.Config) error{
+	"address": func(c *config.Config) (err error) {
+		c.Nodes, err = config.ParseAddrs(addresses)
+		return err
+	},
+	"nodes-file":       func(c *config.Config) error { c.NodesFile = nodesFile; return nil },
+	"nodes-reload":     func(c *config.Config) error { c.NodesReloadMs = nodesReload; return nil },
+	"queue":            func(c *config.Config) error { c.Queue.Algorithm = queueType; return nil },
+	"selector":         func(c *config.Config) error { c.Selector = sel; return nil },
+	"load":             func(c *config.Config) error { c.Strategy = loadStrat; return nil },
+	"mode":             func(c *config.Config) error { c.Mode = mode; return nil },
+	"port":             func(c *config.Config) error { c.Port = port; return nil },
+	"grpc-port":        func(c *config.Config) error { c.GRPCPort = grpcPort; return nil },
+	"batchsize":        func(c *config.Config) error { c.Batch.Size = batSize; return nil },
+	"batchtimeout":     func(c *config.Config) error { c.Batch.TimeoutMs = batTimeout; return nil },
+	"workers":          func(c *config.Config) error { c.Workers = numWorkers; return nil },
+	"breaker-failures": func(c *config.Config) error { c.Breaker.Failures = breakerFailures; return nil },
+	"breaker-timeout":  func(c *config.Config) error { c.Breaker.TimeoutMs = breakerTimeout; return nil },
+	"breaker-probes":   func(c *config.Config) error { c.Breaker.Probes = breakerProbes; return nil },
+	"request-timeout":  func(c *config.Config) error { c.Timeouts.RequestMs = requestTimeout; return nil },
+	"drain-timeout":    func(c *config.Config) error { c.Timeouts.DrainMs = drainTimeout; return nil },
+	"shutdown-timeout": func(c *config.Config) error { c.Timeouts.ShutdownMs = shutdownTimeout; return nil },
+	"retry-attempts":   func(c *config.Config) error { c.Retry.Attempts = retryAttempts; return nil },
+	"retry-backoff":    func(c *config.Config) error { c.Retry.BackoffMs = retryBackoff; return nil },
+	"slow-start":       func(c *config.Config) error { c.SlowStartMs = slowStart; return nil },
+	"trace-file":       func(c *config.Config) error { c.TraceFile = traceFile; return nil },
+	"log-level":        func(c *config.Config) error { c.LogLevel = logLevel; return nil },
+	"tls-cert":         func(c *config.Config) error { c.TLS.Cert = tlsCert; return nil },
+	"tls-key":          func(c *config.Config) error { c.TLS.Key = tlsKey; return nil },
+	"api-keys":         func(c *config.Config) error { c.Auth.KeysFile = apiKeys; return nil },
+	"rate-limit":       func(c *config.Config) error { c.RateLimit.Rate = rateLimit; return nil },
+	"rate-burst":       func(c *config.Config) error { c.RateLimit.Burst = rateBurst; return nil },
+	"concurrency":      func(c *config.Config) error { c.Concurrency.Algorithm = concurrencyAlgo; return nil },
+	"concurrency-max":  func(c *config.Config) error { c.Concurrency.Max = concurrencyMax; return nil },
+	"hedge-percentile": func(c *config.Config) error { c.Hedge.Percentile = hedgePercentile; return nil },
+	"coalesce-writes":  func(c *config.Config) error { c.Batch.CoalesceWrites = coalesceWrites; return nil },
+	"batch-adaptive":   func(c *config.Config) error { c.Batch.Adaptive = batchAdaptive; return nil },
+	"batch-max-bytes":  func(c *config.Config) error { c.Batch.MaxBytes = batchMaxBytes; return nil },
+	"batch-max-age":    func(c *config.Config) error { c.Batch.MaxAgeMs = batchMaxAge; return nil },
+	"cache-size":       func(c *config.Config) error { c.Cache.Size = cacheSize; return nil },
+	"cache-ttl":        func(c *config.Config) error { c.Cache.TTLMs = cacheTTL; return nil },
+	"admission-target": func(c *config.Config) error { c.Admission.TargetMs = admissionTarget; return nil },
+	"admission-interval": func(c *config.Config) error {
+		c.Admission.IntervalMs = admissionInterval
+		return nil
+	},
+	"backend-ca":   func(c *config.Config) error { c.BackendTLS.CA = backendCA; return nil },
+	"backend-cert": func(c *config.Config) error { c.BackendTLS.Cert = backendCert; return nil },
+	"backend-key":  func(c *config.Config) error { c.BackendTLS.Key = backendKey; return nil },
+	"backend-server-name": func(c *config.Config) error {
+		c.BackendTLS.ServerName = backendServerName
+		return nil
+	},
+}
+
 // Global var
+var conf config.Config
 var regis = registry.NewRegistry()
 var s selector.Selector
-var q queue.Queue
-var strat worker.LoadBalancingStrategy
+var q *queue.Instrumented
+var strat strategy.Strategy
 
 var rootCmd = &cobra.Command{
-	Use:     "load-manager",
-	Short:   "Load Manager CLI for Distributed System",
-	Long:    "A Load manager that distrubtes requests across multiple backends",
-	Example: "load-manager --a host1:5000 --a host2:5000 --q FCFS --s RR --l M",
+	Use:   "load-manager",
+	Short: "Load Manager CLI for Distributed System",
+	Long:  "A Load manager that distrubtes requests across multiple backends",
+	Example: "load-manager --a host1:5000 --a host2:5000 --q FCFS --s RR --l M\n" +
+		"load-manager --config lm.yaml --workers 8",
 	PreRunE: preRunE,
 	RunE:    runE,
 }
 
+var validateCmd = &cobra.Command{
+	Use:   "validate-config FILE",
+	Short: "Check a config file without starting the server",
+	Long:  "Checks FILE with LM_* environment variables applied, the way the server would load it",
+	Args:  cobra.ExactArgs(1),
+	// Config errors are not usage errors
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := loadConfig(args[0], nil); err != nil {
+			return err
+		}
+		fmt.Printf("%s is valid\n", args[0])
+		return nil
+	},
+}
+
+var genCertsCmd = &cobra.Command{
+	Use:   "gen-certs DIR [HOST...]",
+	Short: "Write a throwaway CA with server and client certificates",
+	Long: "Writes ca.pem, server.pem, server-key.pem, client.pem and client-key.pem to DIR, " +
+		"the server certificate is valid for HOSTs (default localhost and 127.0.0.1). For tests only",
+	Args:         cobra.MinimumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hosts := args[1:]
+		if len(hosts) == 0 {
+			hosts = []string{"localhost", "127.0.0.1"}
+		}
+		if err := os.MkdirAll(args[0], 0o755); err != nil {
+			return err
+		}
+		return certs.GenerateTestCA(args[0], hosts...)
+	},
+}
+
+// Defaults, then the file if any, then env, then override
+func loadConfig(path string, override func(c *config.Config) error) (config.Config, error) {
+	c := config.Default()
+	if path != "" {
+		var err error
+		if c, err = config.Load(path); err != nil {
+			return c, fmt.Errorf("invalid config %s: %w", path, err)
+		}
+	}
+	if err := c.ApplyEnv(); err != nil {
+		return c, err
+	}
+	if override != nil {
+		if err := override(&c); err != nil {
+			return c, err
+		}
+	}
+	if err := c.Validate(); err != nil {
+		return c, fmt.Errorf("invalid config: %w", err)
+	}
+	return c, nil
+}
+
 func preRunE(cmd *cobra.Command, args []string) error {
-	// Check for algos
-	switch queueType {
-	case "FCFS":
-		q = algorithms.NewFCFSQueue()
-	case "SJF":
-		q = algorithms.NewSJF()
-	case "LJF":
-		q = algorithms.NewLJF()
-	case "RANDOM":
-		q = algorithms.NewRand()
-	case "STACK":
-		q = algorithms.NewStackQueue()
-	default:
-		return fmt.Errorf("invalid queue type %s. Must be: FCFS, SJF, LJF, RANDOM", queueType)
-	}
-
-	// Check for load strat
-	switch loadStrat {
-	case "M":
-		strat = worker.Mixed
-	case "PR":
-		strat = worker.PerResource
-	case "PO":
-		strat = worker.PerOperation
-	case "PRO":
-		strat = worker.PerResourceAndOperation
-	default:
-		return fmt.Errorf("invalid load strat %s. Must be: M, PR, PO, PRO", loadStrat)
-	}
-
-	// Check for selector
-	switch sel {
-	case "RR":
-		s = selector.NewRR()
-	case "RAND":
-		s = selector.NewRand()
-	default:
-		return fmt.Errorf("invalid selector %s. Must be: RR, RAND", sel)
+	// Flags given on the command line win
+	applyFlags := func(c *config.Config) (err error) {
+		cmd.Flags().Visit(func(f *pflag.Flag) {
+			if override, ok := flagOverrides[f.Name]; ok && err == nil {
+				err = override(c)
+			}
+		})
+		return err
+	}
+
+	var err error
+	conf, err = loadConfig(configPath, applyFlags)
+	if err != nil {
+		return err
+	}
+
+	// Logging
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(conf.LogLevel)); err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+
+	// Names were checked by Validate
+	base, err := algorithms.NewQueue(conf.Queue.Algorithm)
+	if err != nil {
+		return err
+	}
+	q = queue.Instrument(base, conf.Queue.Algorithm)
+
+	strat, err = strategy.NewStrategy(conf.Strategy)
+	if err != nil {
+		return err
+	}
+
+	s, err = selector.NewSelector(conf.Selector)
+	if err != nil {
+		return err
 	}
 
 	return nil
 }
 
 func runE(cmd *cobra.Command, args []string) error {
-	// Add the addresses to registry
-	err := parseAddrs(addresses)
-	if err != nil {
-		return err
+	// Tracing
+	if conf.TraceFile != "" {
+		exporter, err := tracing.NewFileExporter(conf.TraceFile)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := exporter.Close(); err != nil {
+				slog.Error("Failed to close trace exporter", "error", err)
+			}
+		}()
+		tracing.SetTracer(tracing.NewTracer("load-manager", exporter))
 	}
 
+	// Circuit breaker for each node
+	regis.SetBreakerConfig(breaker.Config{
+		FailureThreshold: conf.Breaker.Failures,
+		OpenTimeout:      millis(conf.Breaker.TimeoutMs),
+		HalfOpenProbes:   conf.Breaker.Probes,
+	})
+	regis.SetSlowStart(millis(conf.SlowStartMs))
+	regis.SetLimiterConfig(concurrency.Config{
+		Algorithm: conf.Concurrency.Algorithm,
+		Initial:   conf.Concurrency.Initial,
+		Min:       conf.Concurrency.Min,
+		Max:       conf.Concurrency.Max,
+		Backoff:   conf.Concurrency.Backoff,
+	})
+
+	// Static nodes, from --address or the config file
+	discovery.AddNodes(regis, conf.Nodes)
+
 	// Health check
 	go regis.HealthCheckLoop()
 
 	// Batcher
-	clients := make(map[string]*grpc.BackendClient)
-	bat := batcher.NewBatcher(q, batSize, time.Duration(batTimeout)*time.Millisecond)
+	clients := make(map[int]*grpc.BackendClient)
+	bat := batcher.NewBatcher(q, conf.Batch.Size, millis(conf.Batch.TimeoutMs))
+	bat.SetCoalesceWrites(conf.Batch.CoalesceWrites)
+	for _, resource := range []queue.JobType{queue.User, queue.Product, queue.Order} {
+		b := conf.Batch.For(resource.String())
+		bat.Configure(resource, batcher.Settings{
+			Size:       b.Size,
+			Timeout:    millis(b.TimeoutMs),
+			Adaptive:   b.Adaptive,
+			MinSize:    b.MinSize,
+			MaxSize:    b.MaxSize,
+			MinTimeout: millis(b.MinTimeoutMs),
+			MaxTimeout: millis(b.MaxTimeoutMs),
+			MaxBytes:   b.MaxBytes,
+			MaxAge:     millis(b.MaxAgeMs),
+		})
+	}
+
+	// Admission control on what the worker pops, the scheduler keeps q
+	var ctrl *admission.Controller
+	var popq queue.Queue = q
+	if conf.Admission.TargetMs > 0 {
+		ctrl = admission.NewController(admission.Config{
+			Target:    millis(conf.Admission.TargetMs),
+			Interval:  millis(conf.Admission.IntervalMs),
+			ShedBelow: conf.Admission.ShedBelow,
+		})
+		popq = admission.NewQueue(q, ctrl)
+	}
 
 	// Worker
-	wrk := worker.NewWorker(q, regis, s, clients, numWorkers, strat)
+	wrk := worker.NewWorker(popq, regis, s, clients, conf.Workers, strat)
+	wrk.SetCallTimeout(millis(conf.Timeouts.RequestMs))
+	wrk.SetRetryPolicy(worker.RetryPolicy{
+		Attempts: conf.Retry.Attempts,
+		Backoff:  millis(conf.Retry.BackoffMs),
+	})
+	wrk.SetBatchObserver(bat.Observe)
+	wrk.SetHedgePolicy(worker.HedgePolicy{
+		Percentile: conf.Hedge.Percentile,
+		MinDelay:   millis(conf.Hedge.MinDelayMs),
+	})
+	if conf.Cache.Size > 0 {
+		wrk.SetCache(cache.NewCache(conf.Cache.Size, millis(conf.Cache.TTLMs)))
+	}
+
+	// TLS to the backends, certificates are reloaded on change
+	if conf.BackendTLS.Enabled() {
+		store, err := certs.NewStore(certs.Files{
+			CA:   conf.BackendTLS.CA,
+			Cert: conf.BackendTLS.Cert,
+			Key:  conf.BackendTLS.Key,
+		}, millis(conf.BackendTLS.ReloadMs))
+		if err != nil {
+			return fmt.Errorf("backend TLS: %w", err)
+		}
+		store.Start()
+		defer store.Stop()
+		creds := credentials.NewTLS(store.ClientConfig(conf.BackendTLS.ServerName))
+		wrk.SetTransportCredentials(creds)
+		regis.SetTransportCredentials(creds)
+	}
+
+	// Queue, selector and strategy can be switched through the admin API
+	sch := scheduler.NewScheduler(scheduler.Config{
+		Queue:    conf.Queue.Algorithm,
+		Selector: conf.Selector,
+		Strategy: conf.Strategy,
+	}, q, wrk)
+
+	// Nodes file
+	var watcher *discovery.FileWatcher
+	if conf.NodesFile != "" {
+		removeNode := func(id int) error {
+			return wrk.RemoveNode(id, millis(conf.Timeouts.DrainMs))
+		}
+		watcher = discovery.NewFileWatcher(conf.NodesFile, regis, removeNode,
+			millis(conf.NodesReloadMs))
+		if err := watcher.Reload(); err != nil {
+			return fmt.Errorf("invalid nodes file %s: %w", conf.NodesFile, err)
+		}
+		watcher.Start()
+	}
+
+	// API keys, reloaded on change
+	var keys *auth.Keys
+	if conf.Auth.KeysFile != "" {
+		var err error
+		if keys, err = auth.NewKeys(conf.Auth.KeysFile, millis(conf.Auth.ReloadMs)); err != nil {
+			return fmt.Errorf("invalid keys file %s: %w", conf.Auth.KeysFile, err)
+		}
+		keys.Start()
+		defer keys.Stop()
+	}
+
+	// Per client rate limit, after auth so keys are told apart
+	limiter := ratelimit.NewLimiter(ratelimit.Rule{
+		Rate:  conf.RateLimit.Rate,
+		Burst: conf.RateLimit.Burst,
+	}, conf.RateLimit.Routes)
+	var guards []gin.HandlerFunc
+	interceptors := []ggrpc.UnaryServerInterceptor{server.PriorityInterceptor(conf.Admission.MaxPriority)}
+	if keys != nil {
+		guards = append(guards, routes.Auth(keys))
+		interceptors = append(interceptors, server.AuthInterceptor(keys))
+	}
+	if limiter.Enabled() {
+		guards = append(guards, routes.RateLimit(limiter))
+		interceptors = append(interceptors, server.RateLimitInterceptor(limiter))
+	}
+	if ctrl != nil {
+		guards = append(guards, routes.Admission(ctrl, millis(conf.Admission.IntervalMs)))
+		interceptors = append(interceptors, server.AdmissionInterceptor(ctrl))
+	}
 
 	// Router
 	router := gin.Default()
-	balancer := router.Group("balancer")
-
-	// Users
-	balancer.POST("/user", routes.CreateUser(bat))
-	balancer.GET("/users", routes.GetUser(bat))
-	balancer.PUT("/user", routes.UpdateUser(bat))
-	balancer.DELETE("/user", routes.DeleteUser(bat))
-
-	// Product
-	balancer.POST("/product", routes.CreateProduct(bat))
-	balancer.GET("/products", routes.GetProduct(bat))
-	balancer.PUT("/product", routes.UpdateProduct(bat))
-	balancer.DELETE("/product", routes.DeleteProduct(bat))
-
-	// Order
-	balancer.POST("/order", routes.CreateOrder(bat))
-	balancer.GET("/orders", routes.GetOrder(bat))
-	balancer.PUT("/order", routes.UpdateOrder(bat))
-	balancer.DELETE("/order", routes.DeleteOrder(bat))
-
-	port := "8000"
+	if conf.Mode == config.ModeProxy {
+		// Straight to the backends' HTTP servers, same selector, breakers and retries
+		prx := proxy.NewProxy(regis, wrk.Selector, proxy.Options{
+			Timeout: millis(conf.Timeouts.RequestMs),
+			Retry: worker.RetryPolicy{
+				Attempts: conf.Retry.Attempts,
+				Backoff:  millis(conf.Retry.BackoffMs),
+			},
+			PortOffset:   conf.Proxy.HTTPPortOffset,
+			MaxBodyBytes: int64(conf.Proxy.MaxBodyBytes),
+		})
+		single := router.Group("single")
+		single.Use(routes.Tracing())
+		single.Use(guards...)
+		single.Any("/*path", gin.WrapH(prx))
+	} else {
+		balancer := router.Group("balancer")
+		balancer.Use(routes.Tracing(), routes.Priority(conf.Admission.MaxPriority))
+		balancer.Use(guards...)
+
+		// Users
+		balancer.POST("/user", routes.CreateUser(bat))
+		balancer.GET("/users", routes.GetUser(bat))
+		balancer.PUT("/user", routes.UpdateUser(bat))
+		balancer.DELETE("/user", routes.DeleteUser(bat))
+		balancer.POST("/users:verb", routes.BulkCreateUsers(bat)) // /users:bulk
+
+		// Product
+		balancer.POST("/product", routes.CreateProduct(bat))
+		balancer.GET("/products", routes.GetProduct(bat))
+		balancer.PUT("/product", routes.UpdateProduct(bat))
+		balancer.DELETE("/product", routes.DeleteProduct(bat))
+		balancer.POST("/products:verb", routes.BulkCreateProducts(bat)) // /products:bulk
+
+		// Order
+		balancer.POST("/order", routes.CreateOrder(bat))
+		balancer.GET("/orders", routes.GetOrder(bat))
+		balancer.PUT("/order", routes.UpdateOrder(bat))
+		balancer.DELETE("/order", routes.DeleteOrder(bat))
+		balancer.POST("/orders:verb", routes.BulkCreateOrders(bat)) // /orders:bulk
+	}
+
+	// Prometheus
+	router.GET("/metrics", gin.WrapH(metrics.Default.Handler()))
+
+	// Admin
+	admin := router.Group("admin")
+	admin.GET("/state", routes.State(sch, q, bat, regis, wrk))
+	admin.GET("/scheduler", routes.GetScheduler(sch))
+	admin.PUT("/scheduler", routes.UpdateScheduler(sch))
+	admin.GET("/nodes", routes.ListNodes(regis))
+	admin.POST("/nodes", routes.AddNode(regis))
+	admin.PUT("/nodes/:id/state", routes.SetNodeState(regis))
+	admin.DELETE("/nodes/:id", routes.RemoveNode(wrk, millis(conf.Timeouts.DrainMs)))
+
 	srv := &http.Server{
-		Addr:    ":" + port,
+		Addr:    ":" + strconv.Itoa(conf.Port),
 		Handler: router,
 	}
 
+	// HTTPS and gRPC front door TLS, certificates are reloaded on change
+	grpcOpts := []ggrpc.ServerOption{ggrpc.ChainUnaryInterceptor(interceptors...)}
+	if conf.TLS.Cert != "" {
+		store, err := certs.NewStore(certs.Files{Cert: conf.TLS.Cert, Key: conf.TLS.Key},
+			millis(conf.TLS.ReloadMs))
+		if err != nil {
+			return fmt.Errorf("TLS: %w", err)
+		}
+		store.Start()
+		defer store.Stop()
+		srv.TLSConfig = store.ServerConfig()
+		grpcOpts = append(grpcOpts, ggrpc.Creds(credentials.NewTLS(store.ServerConfig())))
+	}
+
 	go func() {
-		log.Printf("Load manager listening on port %s", port)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
+		slog.Info("Load manager listening", "port", conf.Port, "mode", conf.Mode,
+			"tls", srv.TLSConfig != nil, "auth", keys != nil)
+		serve := srv.ListenAndServe
+		if srv.TLSConfig != nil {
+			serve = func() error { return srv.ListenAndServeTLS("", "") }
+		}
+		if err := serve(); err != nil && err != http.ErrServerClosed {
+			slog.Error("Failed to start server", "error", err)
+			os.Exit(1)
 		}
 	}()
 
+	// gRPC front door, same services as the backends
+	var grpcServer *ggrpc.Server
+	if conf.GRPCPort != 0 {
+		listener, err := net.Listen("tcp", ":"+strconv.Itoa(conf.GRPCPort))
+		if err != nil {
+			return err
+		}
+		grpcServer = server.NewGRPCServer(bat, grpcOpts...)
+		go func() {
+			slog.Info("gRPC front door listening", "port", conf.GRPCPort)
+			if err := grpcServer.Serve(listener); err != nil {
+				slog.Error("Failed to serve gRPC", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
 	// Graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down...")
+	slog.Info("Shutting down...")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), millis(conf.Timeouts.ShutdownMs))
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatal("Server forced to shutdown: ", err)
+		return fmt.Errorf("server forced to shutdown: %w", err)
+	}
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
 	}
 
+	if watcher != nil {
+		watcher.Stop()
+	}
 	wrk.Stop()
 	bat.Stop()
 
 	return nil
 }
 
-func parseAddrs(addrs []string) error {
-	for _, addr := range addrs {
-		parts := strings.Split(addr, ":")
-		if len(parts) != 2 {
-			return fmt.Errorf("invalid address format %s. Expected host:port", addr)
-		}
-
-		host := parts[0]
-		port, err := strconv.Atoi(parts[1])
-		if err != nil {
-			return err
-		}
-
-		if port < 1 || port > 65535 {
-			return fmt.Errorf("port out of range %s", addr)
-		}
-		regis.Add(host, port)
-	}
-
-	return nil
+func millis(ms int) time.Duration {
+	return time.Duration(ms) * time.Millisecond
 }
 
 func init() {
+	def := config.Default()
+
+	rootCmd.Flags().StringVarP(&configPath, "config", "c", "", "YAML config file, flags and LM_* env vars override it")
+
 	// []str
 	rootCmd.Flags().StringSliceVarP(&addresses, "address", "a", []string{}, "Server addresses")
+	rootCmd.Flags().StringVar(&nodesFile, "nodes-file", "", "YAML file listing backends, reloaded on change")
 
 	// Str
-	rootCmd.Flags().StringVarP(&queueType, "queue", "q", "FCFS", "Queue algorithms: FCFS\n")
-	rootCmd.Flags().StringVarP(&loadStrat, "load", "l", "M", "Load strategy: M\nPR\nPO\nPRO")
-	rootCmd.Flags().StringVarP(&sel, "selector", "s", "RR", "Selector: RR\nRAND")
+	rootCmd.Flags().StringVar(&mode, "mode", def.Mode, "batch: queue and batch over gRPC\nproxy: forward /single requests to backend HTTP servers")
+	rootCmd.Flags().StringVarP(&queueType, "queue", "q", def.Queue.Algorithm, "Queue algorithms: FCFS\nSJF\nLJF\nRANDOM\nSTACK")
+	rootCmd.Flags().StringVarP(&loadStrat, "load", "l", def.Strategy, "Load strategy: M\nPR\nPO\nPRO")
+	rootCmd.Flags().StringVarP(&sel, "selector", "s", def.Selector, "Selector: RR\nRAND\nWRR")
 
 	// Int
-	rootCmd.Flags().IntVarP(&batSize, "batchsize", "b", 100, "Batch Size")
-	rootCmd.Flags().IntVarP(&batTimeout, "batchtimeout", "t", 2, "Batch Timeout")
-	rootCmd.Flags().IntVarP(&numWorkers, "workers", "w", 4, "Worker size")
-
-	// Required
-	err := rootCmd.MarkFlagRequired("address")
-	if err != nil {
-		log.Fatal(err)
-	}
-	err = rootCmd.MarkFlagRequired("queue")
-	if err != nil {
-		log.Fatal(err)
-	}
-	err = rootCmd.MarkFlagRequired("selector")
-	if err != nil {
-		log.Fatal(err)
-	}
-	err = rootCmd.MarkFlagRequired("load")
-	if err != nil {
-		log.Fatal(err)
-	}
+	rootCmd.Flags().IntVarP(&port, "port", "p", def.Port, "HTTP listen port")
+	rootCmd.Flags().IntVar(&grpcPort, "grpc-port", def.GRPCPort, "gRPC front door port, 0 disables")
+	rootCmd.Flags().IntVarP(&batSize, "batchsize", "b", def.Batch.Size, "Batch Size")
+	rootCmd.Flags().IntVarP(&batTimeout, "batchtimeout", "t", def.Batch.TimeoutMs, "Batch Timeout")
+	rootCmd.Flags().IntVarP(&numWorkers, "workers", "w", def.Workers, "Worker size")
+	rootCmd.Flags().IntVar(&breakerFailures, "breaker-failures", def.Breaker.Failures, "Consecutive failures before a node's circuit opens")
+	rootCmd.Flags().IntVar(&breakerTimeout, "breaker-timeout", def.Breaker.TimeoutMs, "Milliseconds a circuit stays open before probing")
+	rootCmd.Flags().IntVar(&breakerProbes, "breaker-probes", def.Breaker.Probes, "Probes let through while a circuit is half-open")
+	rootCmd.Flags().IntVar(&requestTimeout, "request-timeout", def.Timeouts.RequestMs, "Milliseconds before a gRPC call to a backend times out")
+	rootCmd.Flags().IntVar(&drainTimeout, "drain-timeout", def.Timeouts.DrainMs, "Milliseconds to wait for in-flight calls when removing a node")
+	rootCmd.Flags().IntVar(&shutdownTimeout, "shutdown-timeout", def.Timeouts.ShutdownMs, "Milliseconds to wait for open HTTP requests on shutdown")
+	rootCmd.Flags().IntVar(&retryAttempts, "retry-attempts", def.Retry.Attempts, "Tries per gRPC call when a backend is unavailable, 1 disables retries")
+	rootCmd.Flags().IntVar(&retryBackoff, "retry-backoff", def.Retry.BackoffMs, "Milliseconds before a retry, multiplied by the attempt number")
+	rootCmd.Flags().IntVar(&slowStart, "slow-start", def.SlowStartMs, "Milliseconds for a new or recovered node to ramp to a full share, 0 disables")
+	rootCmd.Flags().StringVar(&traceFile, "trace-file", def.TraceFile, "Append spans as JSON lines to this file, tracing is off when empty")
+	rootCmd.Flags().IntVar(&nodesReload, "nodes-reload", def.NodesReloadMs, "Milliseconds between nodes file checks")
+	rootCmd.Flags().StringVar(&logLevel, "log-level", def.LogLevel, "Log level: debug\ninfo\nwarn\nerror")
+
+	rootCmd.Flags().StringVar(&tlsCert, "tls-cert", def.TLS.Cert, "Certificate for HTTPS and the gRPC front door, enables TLS")
+	rootCmd.Flags().StringVar(&tlsKey, "tls-key", def.TLS.Key, "Key of --tls-cert")
+	rootCmd.Flags().StringVar(&apiKeys, "api-keys", def.Auth.KeysFile, "YAML file of API keys required on /balancer, reloaded on change")
+	rootCmd.Flags().Float64Var(&rateLimit, "rate-limit", def.RateLimit.Rate, "Requests per second per API key or client IP, 0 disables")
+	rootCmd.Flags().IntVar(&rateBurst, "rate-burst", def.RateLimit.Burst, "Requests a client can make at once, defaults to --rate-limit")
+	rootCmd.Flags().StringVar(&concurrencyAlgo, "concurrency", def.Concurrency.Algorithm, "Adaptive limit on calls in flight per node: aimd, gradient, unbounded when empty")
+	rootCmd.Flags().IntVar(&concurrencyMax, "concurrency-max", def.Concurrency.Max, "Highest concurrency limit per node")
+	rootCmd.Flags().Float64Var(&hedgePercentile, "hedge-percentile", def.Hedge.Percentile, "Send reads slower than this percentile of recent latency to a second node too, 0 disables")
+	rootCmd.Flags().BoolVar(&coalesceWrites, "coalesce-writes", def.Batch.CoalesceWrites, "Drop writes superseded by a later write to the same record in the batch")
+	rootCmd.Flags().BoolVar(&batchAdaptive, "batch-adaptive", def.Batch.Adaptive, "Adapt batch size and timeout to backend latency and arrival rate")
+	rootCmd.Flags().IntVar(&batchMaxBytes, "batch-max-bytes", def.Batch.MaxBytes, "Flush a batch once its payloads add up to this many bytes, 0 is off")
+	rootCmd.Flags().IntVar(&batchMaxAge, "batch-max-age", def.Batch.MaxAgeMs, "Flush a batch once its oldest job waited this many ms, 0 is off")
+	rootCmd.Flags().IntVar(&cacheSize, "cache-size", def.Cache.Size, "Backend read responses to cache, 0 disables")
+	rootCmd.Flags().IntVar(&cacheTTL, "cache-ttl", def.Cache.TTLMs, "How long in ms a cached read is served")
+	rootCmd.Flags().IntVar(&admissionTarget, "admission-target", def.Admission.TargetMs, "Acceptable queue wait in ms before shedding load, 0 disables")
+	rootCmd.Flags().IntVar(&admissionInterval, "admission-interval", def.Admission.IntervalMs, "How long in ms the queue wait may stay above target")
+	rootCmd.Flags().StringVar(&backendCA, "backend-ca", def.BackendTLS.CA, "CA file verifying backends, enables TLS")
+	rootCmd.Flags().StringVar(&backendCert, "backend-cert", def.BackendTLS.Cert, "Client certificate for mutual TLS with backends, enables TLS")
+	rootCmd.Flags().StringVar(&backendKey, "backend-key", def.BackendTLS.Key, "Key of --backend-cert")
+	rootCmd.Flags().StringVar(&backendServerName, "backend-server-name", def.BackendTLS.ServerName, "Name expected in backend certificates, default the node host")
+
+	// Nodes can come from the config file, Validate checks one source is set
+	rootCmd.MarkFlagsMutuallyExclusive("address", "nodes-file")
+
+	rootCmd.AddCommand(validateCmd)
+	rootCmd.AddCommand(genCertsCmd)
 }
 
 func main() {
+	// Cobra already printed the error
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
+		os.Exit(1)
 	}
 }
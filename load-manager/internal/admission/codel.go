@@ -0,0 +1,218 @@
+package admission
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/metrics"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+)
+
+// ErrShed completes jobs dropped from the queue under overload
+var ErrShed = errors.New("shed under load")
+
+// Where jobs are shed
+const (
+	stageArrival = "arrival"
+	stageQueue   = "queue"
+)
+
+var (
+	sojourn = metrics.NewHistogramVec("lm_queue_sojourn_seconds",
+		"Time from job creation to pop", metrics.DefBuckets)
+	shedTotal = metrics.NewCounterVec("lm_admission_shed_total",
+		"Jobs shed by admission control", "stage")
+	droppingGauge = metrics.NewGaugeVec("lm_admission_dropping",
+		"1 while sojourn time has stayed above target")
+)
+
+type Config struct {
+	Target    time.Duration // acceptable sojourn time
+	Interval  time.Duration // how long it may stay above Target
+	ShedBelow int           // arrivals with a lower priority are turned away while dropping
+}
+
+/*
+Controller is CoDel over job sojourn time, pop time minus Job.CreatedAt.
+Once the lowest sojourn of every batch has stayed above Target for an
+Interval it starts dropping: popped jobs are dropped lowest priority first,
+at Interval/sqrt(drops) apart like CoDel, and arrivals below ShedBelow are
+turned away. It stops as soon as a batch comes in under Target
+*/
+type Controller struct {
+	conf Config
+	now  func() time.Time
+
+	mutex      sync.Mutex
+	firstAbove time.Time // when the sojourn will have been above target for an interval
+	dropNext   time.Time
+	count      int // drops in this dropping episode
+	lastCount  int
+	dropping   atomic.Bool // read without the mutex by Admit
+}
+
+func (c *Controller) controlLaw(t time.Time) time.Time {
+	return t.Add(time.Duration(float64(c.conf.Interval) / math.Sqrt(float64(c.count))))
+}
+
+// okToDrop reports whether the sojourn has been above target for an interval
+func (c *Controller) okToDropLocked(minSojourn time.Duration, now time.Time) bool {
+	if minSojourn < c.conf.Target {
+		c.firstAbove = time.Time{}
+		return false
+	}
+	if c.firstAbove.IsZero() {
+		c.firstAbove = now.Add(c.conf.Interval)
+		return false
+	}
+	return !now.Before(c.firstAbove)
+}
+
+func (c *Controller) setDropping(dropping bool) {
+	if c.dropping.Swap(dropping) == dropping {
+		return
+	}
+	if dropping {
+		droppingGauge.With().Set(1)
+		slog.Warn("Queue sojourn above target, shedding load", "target", c.conf.Target)
+	} else {
+		droppingGauge.With().Set(0)
+		slog.Info("Queue sojourn back under target", "dropped", c.count)
+	}
+}
+
+/*
+Dequeue runs CoDel on a popped batch, it returns the jobs to dispatch and
+the ones to drop. An empty batch means the queue drained and ends dropping.
+Nil entries are kept as they are
+*/
+func (c *Controller) Dequeue(jobs []*queue.Job) (keep, drop []*queue.Job) {
+	now := c.now()
+	minSojourn := time.Duration(math.MaxInt64)
+	for _, job := range jobs {
+		if job == nil {
+			continue
+		}
+		s := now.Sub(job.CreatedAt)
+		sojourn.With().Observe(s.Seconds())
+		minSojourn = min(minSojourn, s)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// An empty queue has no standing delay
+	if minSojourn == math.MaxInt64 {
+		c.firstAbove = time.Time{}
+		c.setDropping(false)
+		return jobs, nil
+	}
+
+	okToDrop := c.okToDropLocked(minSojourn, now)
+	drops := 0
+	switch {
+	case c.dropping.Load() && !okToDrop:
+		c.setDropping(false)
+	case c.dropping.Load():
+		for drops < len(jobs) && !now.Before(c.dropNext) {
+			drops++
+			c.count++
+			c.dropNext = c.controlLaw(c.dropNext)
+		}
+	case okToDrop:
+		// Back soon after the last episode, resume near its drop rate
+		delta := c.count - c.lastCount
+		c.count = 1
+		if delta > 1 && now.Sub(c.dropNext) < 16*c.conf.Interval {
+			c.count = delta
+		}
+		c.lastCount = c.count
+		c.dropNext = c.controlLaw(now)
+		c.setDropping(true)
+		drops = 1
+	}
+	if drops == 0 {
+		return jobs, nil
+	}
+
+	// Lowest priority first, the oldest of those first
+	order := slices.Clone(jobs)
+	order = slices.DeleteFunc(order, func(job *queue.Job) bool { return job == nil })
+	slices.SortStableFunc(order, func(a, b *queue.Job) int {
+		if a.Priority != b.Priority {
+			return a.Priority - b.Priority
+		}
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	drop = order[:min(drops, len(order))]
+
+	keep = make([]*queue.Job, 0, len(jobs)-len(drop))
+	for _, job := range jobs {
+		if !slices.Contains(drop, job) {
+			keep = append(keep, job)
+		}
+	}
+	shedTotal.With(stageQueue).Add(float64(len(drop)))
+	return keep, drop
+}
+
+// Admit turns away arrivals below ShedBelow while dropping
+func (c *Controller) Admit(priority int) bool {
+	if c.dropping.Load() && priority < c.conf.ShedBelow {
+		shedTotal.With(stageArrival).Inc()
+		return false
+	}
+	return true
+}
+
+// Dropping is true while sojourn time is over target
+func (c *Controller) Dropping() bool {
+	return c.dropping.Load()
+}
+
+func NewController(conf Config) *Controller {
+	droppingGauge.With().Set(0)
+	return &Controller{
+		conf: conf,
+		now:  time.Now,
+	}
+}
+
+/*
+Queue applies the controller to everything the worker pops, dropped jobs
+are completed with ErrShed. Pushes go straight through, arrivals are
+checked before the batcher with Admit
+*/
+type Queue struct {
+	queue.Queue
+	controller *Controller
+}
+
+func (q *Queue) Pops() ([]*queue.Job, []error) {
+	jobs, errs := q.Queue.Pops()
+	keep, drop := q.controller.Dequeue(jobs)
+	for _, job := range drop {
+		job.Complete(nil, ErrShed)
+	}
+	if len(drop) > 0 {
+		slog.Debug("Shed jobs from queue", "job_ids", jobIDs(drop))
+	}
+	return keep, errs
+}
+
+func jobIDs(jobs []*queue.Job) []int {
+	ids := make([]int, 0, len(jobs))
+	for _, job := range jobs {
+		ids = append(ids, job.ID)
+	}
+	return ids
+}
+
+func NewQueue(q queue.Queue, controller *Controller) *Queue {
+	return &Queue{Queue: q, controller: controller}
+}
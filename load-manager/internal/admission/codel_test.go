@@ -0,0 +1,85 @@
+package admission
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+)
+
+func controller() (*Controller, *time.Time) {
+	c := NewController(Config{Target: 10 * time.Millisecond, Interval: 100 * time.Millisecond, ShedBelow: 1})
+	now := time.Unix(1000, 0)
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+// Jobs created wait ago, with the given priorities
+func batch(now time.Time, wait time.Duration, priorities ...int) []*queue.Job {
+	jobs := make([]*queue.Job, len(priorities))
+	for i, p := range priorities {
+		jobs[i] = &queue.Job{ID: i, Priority: p, CreatedAt: now.Add(-wait)}
+	}
+	return jobs
+}
+
+func TestController_DropsAfterInterval(t *testing.T) {
+	c, now := controller()
+
+	// Above target, but not for an interval yet
+	if _, drop := c.Dequeue(batch(*now, 50*time.Millisecond, 0, 0)); len(drop) != 0 {
+		t.Fatalf("Expected no drops before an interval, got %d", len(drop))
+	}
+	*now = now.Add(50 * time.Millisecond)
+	if _, drop := c.Dequeue(batch(*now, 50*time.Millisecond, 0, 0)); len(drop) != 0 || c.Dropping() {
+		t.Fatalf("Expected no drops before an interval, got %d", len(drop))
+	}
+
+	*now = now.Add(60 * time.Millisecond)
+	keep, drop := c.Dequeue(batch(*now, 50*time.Millisecond, 2, 0, 1))
+	if len(drop) != 1 || drop[0].Priority != 0 || len(keep) != 2 {
+		t.Fatalf("Expected the priority 0 job dropped, got drop %v keep %v", drop, keep)
+	}
+	if !c.Dropping() {
+		t.Fatalf("Expected controller to be dropping")
+	}
+	if c.Admit(0) || !c.Admit(1) {
+		t.Errorf("Expected only arrivals below priority 1 to be turned away")
+	}
+
+	// Next drop comes interval/sqrt(count) later
+	if _, drop := c.Dequeue(batch(*now, 50*time.Millisecond, 0)); len(drop) != 0 {
+		t.Errorf("Expected no drop before the next drop time, got %d", len(drop))
+	}
+	*now = now.Add(100 * time.Millisecond)
+	if _, drop := c.Dequeue(batch(*now, 50*time.Millisecond, 0, 0)); len(drop) != 1 {
+		t.Errorf("Expected a drop at the next drop time, got %d", len(drop))
+	}
+}
+
+func TestController_StopsBelowTarget(t *testing.T) {
+	c, now := controller()
+	c.Dequeue(batch(*now, 50*time.Millisecond, 0))
+	*now = now.Add(200 * time.Millisecond)
+	c.Dequeue(batch(*now, 50*time.Millisecond, 0))
+	if !c.Dropping() {
+		t.Fatalf("Expected controller to be dropping")
+	}
+
+	// One fresh job in the batch is enough
+	*now = now.Add(time.Millisecond)
+	jobs := append(batch(*now, 50*time.Millisecond, 0), batch(*now, time.Millisecond, 0)...)
+	if _, drop := c.Dequeue(jobs); len(drop) != 0 || c.Dropping() {
+		t.Errorf("Expected dropping to stop below target, dropped %d", len(drop))
+	}
+
+	// So does an empty queue
+	*now = now.Add(200 * time.Millisecond)
+	c.Dequeue(batch(*now, 50*time.Millisecond, 0))
+	*now = now.Add(200 * time.Millisecond)
+	c.Dequeue(batch(*now, 50*time.Millisecond, 0))
+	c.Dequeue(nil)
+	if c.Dropping() || !c.Admit(0) {
+		t.Errorf("Expected an empty queue to end dropping")
+	}
+}
@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/sudo-JP/Load-Manager/common/filewatch"
+)
+
+// Scopes, a key needs read for GET requests and write for everything else
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+var resources = []string{"user", "product", "order"}
+
+// IsResource reports whether name is a resource keys can be limited to
+func IsResource(name string) bool {
+	return slices.Contains(resources, name)
+}
+
+var (
+	ErrNoKey      = errors.New("missing API key")
+	ErrUnknownKey = errors.New("unknown API key")
+	ErrForbidden  = errors.New("API key not allowed")
+)
+
+/*
+API keys file, reloaded on change
+
+	keys:
+	  - id: importer
+	    key: 0b6e...              # or key_sha256: <hex sha256 of the key>
+	    scopes: [read, write]
+	    resources: [user, product] # all when omitted
+	    max_priority: 5            # highest X-Priority honoured, 0 when omitted
+	  - id: dashboard
+	    key_sha256: 9f86d08...
+	    scopes: [read]
+*/
+type KeysFile struct {
+	Keys []FileKey `yaml:"keys"`
+}
+
+type FileKey struct {
+	ID        string   `yaml:"id"` // logged, never the key itself
+	Key       string   `yaml:"key"`
+	KeySHA256 string   `yaml:"key_sha256"`
+	Scopes    []string `yaml:"scopes"`
+	Resources []string `yaml:"resources"`
+	// Higher X-Priority values from this key are lowered to it
+	MaxPriority int `yaml:"max_priority"`
+}
+
+func (f FileKey) hash() ([32]byte, error) {
+	var sum [32]byte
+	if f.Key != "" {
+		return sha256.Sum256([]byte(f.Key)), nil
+	}
+	raw, err := hex.DecodeString(f.KeySHA256)
+	if err != nil || len(raw) != len(sum) {
+		return sum, errors.New("not a hex SHA-256")
+	}
+	copy(sum[:], raw)
+	return sum, nil
+}
+
+// ParseKeysFile names the first offending field, e.g. keys[1].scopes
+func ParseKeysFile(data []byte) (*KeysFile, error) {
+	var file KeysFile
+	if err := yaml.UnmarshalWithOptions(data, &file, yaml.Strict()); err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]bool)
+	hashes := make(map[[32]byte]bool)
+	for i, key := range file.Keys {
+		if key.ID == "" {
+			return nil, fmt.Errorf("keys[%d].id: required", i)
+		}
+		if ids[key.ID] {
+			return nil, fmt.Errorf("keys[%d].id: duplicate id %s", i, key.ID)
+		}
+		ids[key.ID] = true
+
+		if (key.Key == "") == (key.KeySHA256 == "") {
+			return nil, fmt.Errorf("keys[%d]: one of key or key_sha256 is required", i)
+		}
+		sum, err := key.hash()
+		if err != nil {
+			return nil, fmt.Errorf("keys[%d].key_sha256: %w", i, err)
+		}
+		if hashes[sum] {
+			return nil, fmt.Errorf("keys[%d]: same key as another entry", i)
+		}
+		hashes[sum] = true
+
+		if len(key.Scopes) == 0 {
+			return nil, fmt.Errorf("keys[%d].scopes: required", i)
+		}
+		for _, scope := range key.Scopes {
+			if scope != ScopeRead && scope != ScopeWrite {
+				return nil, fmt.Errorf("keys[%d].scopes: invalid scope %s. Must be: read, write", i, scope)
+			}
+		}
+		for _, resource := range key.Resources {
+			if !slices.Contains(resources, resource) {
+				return nil, fmt.Errorf("keys[%d].resources: invalid resource %s. Must be: user, product, order", i, resource)
+			}
+		}
+	}
+	return &file, nil
+}
+
+type key struct {
+	id          string
+	scopes      []string
+	resources   []string // empty allows all
+	maxPriority int
+}
+
+/*
+Keys checks API keys against a keys file. Only SHA-256 hashes of the keys
+are kept in memory. A file that fails to parse keeps the keys already loaded
+*/
+type Keys struct {
+	watcher *filewatch.Watcher
+
+	mutex sync.RWMutex
+	keys  map[[32]byte]*key
+	byID  map[string]*key
+}
+
+// Fingerprint identifies a key in logs without revealing it
+func Fingerprint(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:4])
+}
+
+/*
+Authorize returns the id of secret's key if it may use resource, with write
+for anything but reads. resource is user, product or order, an empty one
+needs a key without resource restrictions. Unknown keys are identified by
+their fingerprint
+*/
+func (k *Keys) Authorize(secret, resource string, write bool) (string, error) {
+	if secret == "" {
+		return "", ErrNoKey
+	}
+
+	k.mutex.RLock()
+	entry, ok := k.keys[sha256.Sum256([]byte(secret))]
+	k.mutex.RUnlock()
+	if !ok {
+		return "unknown:" + Fingerprint(secret), ErrUnknownKey
+	}
+
+	scope := ScopeRead
+	if write {
+		scope = ScopeWrite
+	}
+	if !slices.Contains(entry.scopes, scope) {
+		return entry.id, fmt.Errorf("%w: no %s scope", ErrForbidden, scope)
+	}
+	if len(entry.resources) > 0 && !slices.Contains(entry.resources, resource) {
+		return entry.id, fmt.Errorf("%w: resource %s", ErrForbidden, resource)
+	}
+	return entry.id, nil
+}
+
+func (k *Keys) Reload() error {
+	return k.watcher.Reload()
+}
+
+func (k *Keys) load(data [][]byte) error {
+	file, err := ParseKeysFile(data[0])
+	if err != nil {
+		return err
+	}
+	keys := make(map[[32]byte]*key, len(file.Keys))
+	byID := make(map[string]*key, len(file.Keys))
+	for _, fileKey := range file.Keys {
+		sum, _ := fileKey.hash() // checked by ParseKeysFile
+		keys[sum] = &key{
+			id:          fileKey.ID,
+			scopes:      fileKey.Scopes,
+			resources:   fileKey.Resources,
+			maxPriority: fileKey.MaxPriority,
+		}
+		byID[fileKey.ID] = keys[sum]
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.keys = keys
+	k.byID = byID
+	return nil
+}
+
+// MaxPriority is the highest priority the key with id may ask for,
+// 0 for keys no longer in the file
+func (k *Keys) MaxPriority(id string) int {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	if entry, ok := k.byID[id]; ok {
+		return entry.maxPriority
+	}
+	return 0
+}
+
+// Start checks the file for changes every interval
+func (k *Keys) Start() {
+	k.watcher.Start()
+}
+
+func (k *Keys) Stop() {
+	k.watcher.Stop()
+}
+
+// NewKeys loads the keys file
+func NewKeys(path string, interval time.Duration) (*Keys, error) {
+	k := &Keys{}
+	k.watcher = filewatch.New("API keys", []string{path}, interval, k.load)
+	if err := k.Reload(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeKeys(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseKeysFile_NamesField(t *testing.T) {
+	cases := map[string]string{
+		"keys:\n  - key: a\n    scopes: [read]\n":                                                       "keys[0].id",
+		"keys:\n  - id: a\n    scopes: [read]\n":                                                        "keys[0]:",
+		"keys:\n  - id: a\n    key_sha256: zz\n    scopes: [read]\n":                                    "keys[0].key_sha256",
+		"keys:\n  - id: a\n    key: a\n    scopes: [admin]\n":                                           "keys[0].scopes",
+		"keys:\n  - id: a\n    key: a\n    scopes: [read]\n    resources: [x]\n":                        "keys[0].resources",
+		"keys:\n  - id: a\n    key: a\n    scopes: [read]\n  - id: a\n    key: b\n    scopes: [read]\n": "keys[1].id",
+	}
+	for data, field := range cases {
+		_, err := ParseKeysFile([]byte(data))
+		if err == nil || !strings.HasPrefix(err.Error(), field) {
+			t.Errorf("Expected error naming %s, got %v", field, err)
+		}
+	}
+}
+
+func TestKeys_Authorize(t *testing.T) {
+	sum := sha256.Sum256([]byte("reader-secret"))
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	writeKeys(t, path, `
+keys:
+  - id: importer
+    key: import-secret
+    scopes: [read, write]
+    resources: [user, product]
+  - id: dashboard
+    key_sha256: `+hex.EncodeToString(sum[:])+`
+    scopes: [read]
+`)
+	keys, err := NewKeys(path, time.Hour)
+	if err != nil {
+		t.Fatalf("NewKeys: %v", err)
+	}
+
+	cases := []struct {
+		secret, resource string
+		write            bool
+		id               string
+		err              error
+	}{
+		{"import-secret", "user", true, "importer", nil},
+		{"import-secret", "order", false, "importer", ErrForbidden},
+		{"reader-secret", "order", false, "dashboard", nil},
+		{"reader-secret", "order", true, "dashboard", ErrForbidden},
+		{"", "user", false, "", ErrNoKey},
+		{"guess", "user", false, "unknown:" + Fingerprint("guess"), ErrUnknownKey},
+	}
+	for _, c := range cases {
+		id, err := keys.Authorize(c.secret, c.resource, c.write)
+		if id != c.id || !errors.Is(err, c.err) {
+			t.Errorf("Authorize(%q, %s, %v) = %s, %v, expected %s, %v",
+				c.secret, c.resource, c.write, id, err, c.id, c.err)
+		}
+	}
+
+	// Revoked on reload, a broken file keeps the current keys
+	writeKeys(t, path, "keys:\n  - id: dashboard\n    key: reader-secret\n    scopes: [read]\n")
+	if err := keys.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if _, err := keys.Authorize("import-secret", "user", false); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected revoked key to be unknown, got %v", err)
+	}
+	writeKeys(t, path, "keys: [")
+	if err := keys.Reload(); err == nil {
+		t.Errorf("Expected Reload to fail on a broken file")
+	}
+	if _, err := keys.Authorize("reader-secret", "user", false); err != nil {
+		t.Errorf("Expected loaded keys to be kept, got %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package batcher
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/metrics"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+)
+
+var (
+	batchSizeGauge = metrics.NewGaugeVec("lm_batcher_batch_size",
+		"Current batch size per resource", "resource")
+	batchTimeoutGauge = metrics.NewGaugeVec("lm_batcher_timeout_seconds",
+		"Current batch timeout per resource", "resource")
+	sizeDecisions = metrics.NewCounterVec("lm_batcher_size_decisions_total",
+		"Adaptive batch size changes, grow or shrink", "resource", "decision")
+	itemLatencyGauge = metrics.NewGaugeVec("lm_batcher_item_latency_seconds",
+		"Smoothed backend latency per item of a batch call", "resource")
+)
+
+// Batch size decisions
+const (
+	decisionGrow   = "grow"
+	decisionShrink = "shrink"
+)
+
+/*
+Settings of one resource's batches. Fixed lanes flush at Size jobs or
+every Timeout. Adaptive lanes start there and move within the bounds:
+the size grows while the backend's latency per item drops and shrinks
+when it rises, the timeout shortens when too few jobs arrive to fill a
+batch in MaxTimeout anyway.
+
+Either way a lane also flushes once its payloads add up to MaxBytes, and
+once its oldest job waited MaxAge whenever the timeout last fired. Both
+are off at 0
+*/
+type Settings struct {
+	Size       int
+	Timeout    time.Duration
+	Adaptive   bool
+	MinSize    int
+	MaxSize    int
+	MinTimeout time.Duration
+	MaxTimeout time.Duration
+	MaxBytes   int
+	MaxAge     time.Duration
+}
+
+const (
+	latencyTolerance = 0.1 // per item latency change that moves the size
+	growFactor       = 1.25
+	shrinkFactor     = 0.8
+	smoothing        = 0.2 // weight of a new latency or arrival sample
+)
+
+// tuner holds a lane's current size and timeout, guarded by the lane's lock
+type tuner struct {
+	resource string
+	conf     Settings
+
+	curSize    int
+	curTimeout time.Duration
+	perItem    time.Duration // smoothed, 0 until the first write
+	rate       float64       // smoothed jobs per second
+	lastFlush  time.Time
+}
+
+func (t *tuner) size() int {
+	return t.curSize
+}
+
+func (t *tuner) timeout() time.Duration {
+	return t.curTimeout
+}
+
+func (t *tuner) setSize(size int, decision string) {
+	size = min(t.conf.MaxSize, max(t.conf.MinSize, size))
+	if size == t.curSize {
+		return
+	}
+	slog.Debug("Batch size changed", "resource", t.resource, "from", t.curSize, "to", size,
+		"item_latency", t.perItem)
+	sizeDecisions.With(t.resource, decision).Inc()
+	t.curSize = size
+	batchSizeGauge.With(t.resource).Set(float64(size))
+}
+
+// observe takes the latency of a batch call of items jobs
+func (t *tuner) observe(items int, latency time.Duration) {
+	if !t.conf.Adaptive {
+		return
+	}
+	sample := latency / time.Duration(items)
+	if t.perItem == 0 {
+		t.perItem = sample
+		return
+	}
+
+	switch {
+	case float64(sample) < float64(t.perItem)*(1-latencyTolerance):
+		t.setSize(max(t.curSize+1, int(float64(t.curSize)*growFactor)), decisionGrow)
+	case float64(sample) > float64(t.perItem)*(1+latencyTolerance):
+		t.setSize(min(t.curSize-1, int(float64(t.curSize)*shrinkFactor)), decisionShrink)
+	}
+	t.perItem = time.Duration(float64(t.perItem)*(1-smoothing) + float64(sample)*smoothing)
+	itemLatencyGauge.With(t.resource).Set(t.perItem.Seconds())
+}
+
+// flushed takes the jobs flushed since the last flush to follow the arrival rate
+func (t *tuner) flushed(jobs int, now time.Time) {
+	if !t.conf.Adaptive {
+		return
+	}
+	if t.lastFlush.IsZero() {
+		t.lastFlush = now
+		return
+	}
+	elapsed := now.Sub(t.lastFlush).Seconds()
+	t.lastFlush = now
+	if elapsed <= 0 {
+		return
+	}
+	t.rate = t.rate*(1-smoothing) + float64(jobs)/elapsed*smoothing
+
+	// Share of a batch that arrives within MaxTimeout
+	fill := min(1, t.rate*t.conf.MaxTimeout.Seconds()/float64(t.curSize))
+	span := t.conf.MaxTimeout - t.conf.MinTimeout
+	t.curTimeout = t.conf.MinTimeout + time.Duration(float64(span)*fill)
+	batchTimeoutGauge.With(t.resource).Set(t.curTimeout.Seconds())
+}
+
+func newTuner(resource queue.JobType, conf Settings) *tuner {
+	if !conf.Adaptive {
+		conf.MinSize, conf.MaxSize = conf.Size, conf.Size
+		conf.MinTimeout, conf.MaxTimeout = conf.Timeout, conf.Timeout
+	}
+	t := &tuner{
+		resource:   resource.String(),
+		conf:       conf,
+		curSize:    min(conf.MaxSize, max(conf.MinSize, conf.Size)),
+		curTimeout: min(conf.MaxTimeout, max(conf.MinTimeout, conf.Timeout)),
+	}
+	batchSizeGauge.With(t.resource).Set(float64(t.curSize))
+	batchTimeoutGauge.With(t.resource).Set(t.curTimeout.Seconds())
+	return t
+}
@@ -0,0 +1,106 @@
+package batcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue/algorithms"
+)
+
+func adaptive() Settings {
+	return Settings{
+		Size:       100,
+		Timeout:    2 * time.Millisecond,
+		Adaptive:   true,
+		MinSize:    10,
+		MaxSize:    200,
+		MinTimeout: time.Millisecond,
+		MaxTimeout: 10 * time.Millisecond,
+	}
+}
+
+func TestTuner_Size(t *testing.T) {
+	tn := newTuner(queue.User, adaptive())
+	tn.observe(100, 100*time.Millisecond) // 1ms per item, the baseline
+
+	tn.observe(100, 50*time.Millisecond)
+	if tn.size() != 125 {
+		t.Errorf("Expected faster items to grow the batch to 125, got %d", tn.size())
+	}
+
+	tn.observe(125, 125*time.Millisecond)
+	tn.observe(125, 500*time.Millisecond)
+	if tn.size() != 80 {
+		t.Errorf("Expected slower items to shrink the batch to 80, got %d", tn.size())
+	}
+
+	for range 20 {
+		tn.observe(tn.size(), time.Duration(tn.size())*time.Second)
+	}
+	if tn.size() != 10 {
+		t.Errorf("Expected the batch to stop at MinSize 10, got %d", tn.size())
+	}
+}
+
+func TestTuner_SteadyLatencyKeepsSize(t *testing.T) {
+	tn := newTuner(queue.User, adaptive())
+	for range 10 {
+		tn.observe(100, 102*time.Millisecond)
+	}
+	if tn.size() != 100 {
+		t.Errorf("Expected latency within tolerance to keep 100, got %d", tn.size())
+	}
+}
+
+func TestTuner_Timeout(t *testing.T) {
+	tn := newTuner(queue.Order, adaptive())
+	now := time.Now()
+	tn.flushed(0, now)
+
+	// A job a second never fills a batch, wait as little as allowed
+	for i := range 10 {
+		tn.flushed(1, now.Add(time.Duration(i+1)*time.Second))
+	}
+	if tn.timeout() > 2*time.Millisecond {
+		t.Errorf("Expected a low arrival rate to shorten the timeout, got %v", tn.timeout())
+	}
+
+	// Batches filling faster than MaxTimeout wait all of it
+	now = now.Add(10 * time.Second)
+	for i := range 50 {
+		tn.flushed(100, now.Add(time.Duration(i+1)*time.Millisecond))
+	}
+	if tn.timeout() != 10*time.Millisecond {
+		t.Errorf("Expected a high arrival rate to reach MaxTimeout, got %v", tn.timeout())
+	}
+}
+
+func TestTuner_Fixed(t *testing.T) {
+	tn := newTuner(queue.Product, Settings{Size: 50, Timeout: 5 * time.Millisecond})
+	tn.observe(50, 50*time.Millisecond)
+	tn.observe(50, time.Millisecond)
+	tn.flushed(1, time.Now())
+	tn.flushed(1, time.Now().Add(time.Second))
+	if tn.size() != 50 || tn.timeout() != 5*time.Millisecond {
+		t.Errorf("Expected fixed settings to stay 50/5ms, got %d/%v", tn.size(), tn.timeout())
+	}
+}
+
+func TestBatcher_PerResourceSize(t *testing.T) {
+	q := algorithms.NewFCFSQueue()
+	b := NewBatcher(q, 100, time.Hour)
+	defer b.Stop()
+	b.Configure(queue.Order, Settings{Size: 2, Timeout: time.Hour})
+
+	for i := range 2 {
+		b.AddOrder(job(i, queue.Order, queue.Create, `{}`))
+		b.AddUser(job(10+i, queue.User, queue.Create, `{}`))
+	}
+	if size, _ := b.Current(queue.Order); size != 2 {
+		t.Errorf("Expected order batches of 2, got %d", size)
+	}
+	if pending := b.Pending(); pending[queue.Order] != 0 || pending[queue.User] != 2 {
+		t.Errorf("Expected only the order lane to flush, got %v", pending)
+	}
+}
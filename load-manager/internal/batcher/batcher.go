@@ -1,107 +1,175 @@
 package batcher
 
 import (
+	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/sudo-JP/Load-Manager/common/tracing"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/metrics"
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
 )
 
-type Batcher struct {
-	queue    	queue.Queue
-	users    	[]*queue.Job
-	products 	[]*queue.Job
-	orders   	[]*queue.Job
-
-	batchSize 	int
-	timeout   	time.Duration
+// Flush triggers
+const (
+	triggerSize    = "size"
+	triggerTimeout = "timeout"
+	triggerBytes   = "bytes"
+	triggerAge     = "age"
+	triggerStop    = "stop"
+)
 
-	mutex  		sync.Mutex
-	timer  		*time.Timer
-	stopCh 		chan struct{}
-}
+var (
+	flushes = metrics.NewCounterVec("lm_batcher_flushes_total",
+		"Non empty batches flushed to the queue", "resource", "trigger")
+	flushedJobs = metrics.NewCounterVec("lm_batcher_flushed_jobs_total",
+		"Jobs flushed to the queue", "resource", "trigger")
+)
 
-func (b *Batcher) AddUser(job *queue.Job) {
-	b.mutex.Lock()
-	defer b.mutex.Unlock()
+func recordFlush(resource queue.JobType, trigger string, jobs []*queue.Job) {
+	if len(jobs) == 0 {
+		return
+	}
+	flushes.With(resource.String(), trigger).Inc()
+	flushedJobs.With(resource.String(), trigger).Add(float64(len(jobs)))
 
-	b.users = append(b.users, job)
-	if len(b.users) >= b.batchSize {
-		b.flushUsersLocked()
+	// Time each job sat in the batcher
+	now := time.Now()
+	for _, job := range jobs {
+		_, span := tracing.Start(context.Background(), "batcher.wait",
+			tracing.WithParent(job.Trace),
+			tracing.WithStart(job.CreatedAt),
+			tracing.WithAttr("job.id", job.ID),
+			tracing.WithAttr("batch.trigger", trigger),
+			tracing.WithAttr("batch.size", len(jobs)))
+		span.EndAt(now)
 	}
 }
 
-func (b *Batcher) AddProduct(job *queue.Job) {
-	b.mutex.Lock()
-	defer b.mutex.Unlock()
+/*
+Batcher buffers jobs per resource and pushes them to the queue once a lane
+holds its batch size or its payload bytes, its timeout passes or its oldest
+job reaches the max age. Each resource has its own settings, adaptive lanes
+tune them as they go, see adaptive.go
+*/
+type Batcher struct {
+	queue    	queue.Queue
+	lanes 		map[queue.JobType]*lane
+	stopCh 		chan struct{}
+	coalesceWrites atomic.Bool // collapse superseded writes before pushing
+}
 
-	b.products = append(b.products, job)
-	if len(b.products) >= b.batchSize {
-		b.flushProductsLocked()
-	}
+// One resource's buffer
+type lane struct {
+	resource 	queue.JobType
+	mutex 		sync.Mutex
+	jobs 		[]*queue.Job
+	bytes 		int // payload bytes of jobs
+	oldest 		time.Time // when the first of jobs came in
+	tuner 		*tuner
+	timer 		*time.Timer
+	age 		*time.Timer // flushes at the oldest job's max age, nil until used
 }
 
-func (b *Batcher) AddOrder(job *queue.Job) {
-	b.mutex.Lock()
-	defer b.mutex.Unlock()
+func (b *Batcher) add(resource queue.JobType, job *queue.Job) {
+	l := b.lanes[resource]
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
 
-	b.orders = append(b.orders, job)
-	if len(b.orders) >= b.batchSize {
-		b.flushOrdersLocked()
+	l.jobs = append(l.jobs, job)
+	l.bytes += len(job.Payload)
+	if len(l.jobs) == 1 {
+		l.oldest = time.Now()
+		b.startAgeLocked(l)
 	}
-}
 
-func (b *Batcher) flush() {
-	b.mutex.Lock()
+	switch maxBytes := l.tuner.conf.MaxBytes; {
+	case len(l.jobs) >= l.tuner.size():
+		b.flushLocked(l, triggerSize)
+	case maxBytes > 0 && l.bytes >= maxBytes:
+		b.flushLocked(l, triggerBytes)
+	}
+}
 
-	users := b.users
-	products := b.products
-	orders := b.orders
+// Must hold the lane's lock, arms the age timer for the job that just came in
+func (b *Batcher) startAgeLocked(l *lane) {
+	maxAge := l.tuner.conf.MaxAge
+	if maxAge <= 0 {
+		return
+	}
+	if l.age == nil {
+		l.age = time.AfterFunc(maxAge, func() { b.flushAged(l) })
+		return
+	}
+	l.age.Reset(maxAge)
+}
 
-	b.users = make([]*queue.Job, 0, b.batchSize)
-	b.products = make([]*queue.Job, 0, b.batchSize)
-	b.orders = make([]*queue.Job, 0, b.batchSize)
+// The age timer may fire late, after a flush and new jobs, so check the
+// jobs buffered now are the old ones
+func (b *Batcher) flushAged(l *lane) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if len(l.jobs) == 0 {
+		return
+	}
+	maxAge := l.tuner.conf.MaxAge
+	if waited := time.Since(l.oldest); maxAge > 0 && waited < maxAge {
+		l.age.Reset(maxAge - waited)
+		return
+	}
+	b.flushLocked(l, triggerAge)
+}
 
-	b.mutex.Unlock()
+func (b *Batcher) AddUser(job *queue.Job) {
+	b.add(queue.User, job)
+}
 
-	b.groupAndPush(users)
-	b.groupAndPush(products)
-	b.groupAndPush(orders)
+func (b *Batcher) AddProduct(job *queue.Job) {
+	b.add(queue.Product, job)
 }
 
-func (b *Batcher) flushProductsLocked() {
-	products := b.products
-	b.products = make([]*queue.Job, 0, b.batchSize)
-	b.mutex.Unlock()
-	b.groupAndPush(products)
-	b.mutex.Lock()
+func (b *Batcher) AddOrder(job *queue.Job) {
+	b.add(queue.Order, job)
 }
 
-func (b *Batcher) flushOrdersLocked() {
-	orders := b.orders
-	b.orders = make([]*queue.Job, 0, b.batchSize)
-	b.mutex.Unlock()
-	b.groupAndPush(orders)
-	b.mutex.Lock()
+// Must hold the lane's lock, it is released while pushing
+func (b *Batcher) flushLocked(l *lane, trigger string) {
+	jobs := l.jobs
+	l.jobs = make([]*queue.Job, 0, l.tuner.size())
+	l.bytes, l.oldest = 0, time.Time{}
+	if l.age != nil {
+		l.age.Stop()
+	}
+	l.tuner.flushed(len(jobs), time.Now())
+	l.mutex.Unlock()
+
+	recordFlush(l.resource, trigger, jobs)
+	b.groupAndPush(jobs)
+	l.mutex.Lock()
 }
 
-func (b *Batcher) flushUsersLocked() {
-	users := b.users 
-	b.users = make([]*queue.Job, 0, b.batchSize)
-	b.mutex.Unlock()
-	b.groupAndPush(users)
-	b.mutex.Lock()
+func (b *Batcher) flush(l *lane, trigger string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	b.flushLocked(l, trigger)
 }
 
+// Jobs are pushed one operation at a time, creates, reads, updates then
+// deletes, each in arrival order. Writes to one record with different
+// operations are not kept in order, the queue and workers decide theirs
 func (b *Batcher) groupAndPush(jobs []*queue.Job) {
+	if b.coalesceWrites.Load() {
+		jobs = b.coalesce(jobs)
+	}
 	if len(jobs) == 0 {
 		return
 	}
 
-	creates := make([]*queue.Job, 0, b.batchSize)
-	reads := make([]*queue.Job, 0, b.batchSize)
-	updates := make([]*queue.Job, 0, b.batchSize)
-	deletes := make([]*queue.Job, 0, b.batchSize)
+	creates := make([]*queue.Job, 0, len(jobs))
+	reads := make([]*queue.Job, 0, len(jobs))
+	updates := make([]*queue.Job, 0, len(jobs))
+	deletes := make([]*queue.Job, 0, len(jobs))
 
 	for _, job := range jobs {
 		switch job.CRUD {
@@ -130,35 +198,97 @@ func (b *Batcher) groupAndPush(jobs []*queue.Job) {
 	}
 }
 
-func (b *Batcher) run() {
+// Each lane flushes on its own timeout, which adaptive lanes may change
+func (b *Batcher) run(l *lane) {
 	for {
 		select {
-		case <-b.timer.C:
-			b.flush()
-			b.timer.Reset(b.timeout)
+		case <-l.timer.C:
+			b.flush(l, triggerTimeout)
+			// Under the lock so it can't undo a Configure
+			l.mutex.Lock()
+			l.timer.Reset(l.tuner.timeout())
+			l.mutex.Unlock()
 		case <-b.stopCh:
 			return
 		}
 	}
 }
 
+// Pending counts the jobs buffered per resource, not yet in the queue
+func (b *Batcher) Pending() map[queue.JobType]int {
+	pending := make(map[queue.JobType]int, len(b.lanes))
+	for resource, l := range b.lanes {
+		l.mutex.Lock()
+		pending[resource] = len(l.jobs)
+		l.mutex.Unlock()
+	}
+	return pending
+}
+
+// Configure replaces a resource's settings, adaptive state starts over
+func (b *Batcher) Configure(resource queue.JobType, settings Settings) {
+	l := b.lanes[resource]
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.tuner = newTuner(resource, settings)
+	// Don't wait out the old timeout
+	l.timer.Reset(l.tuner.timeout())
+}
+
+// Current batch size and timeout of a resource
+func (b *Batcher) Current(resource queue.JobType) (int, time.Duration) {
+	l := b.lanes[resource]
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.tuner.size(), l.tuner.timeout()
+}
+
+/*
+Observe feeds back how long the backend took for a batch call of items
+jobs of resource, adaptive lanes size their batches from it. The worker
+calls it after every write
+*/
+func (b *Batcher) Observe(resource queue.JobType, items int, latency time.Duration) {
+	l, ok := b.lanes[resource]
+	if !ok || items == 0 {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.tuner.observe(items, latency)
+}
+
+// SetCoalesceWrites turns the superseded write stage on or off, it is off by default
+func (b *Batcher) SetCoalesceWrites(on bool) {
+	b.coalesceWrites.Store(on)
+}
+
 func (b *Batcher) Stop() {
 	close(b.stopCh)
-	b.flush()
+	for _, l := range b.lanes {
+		l.timer.Stop()
+		b.flush(l, triggerStop)
+	}
 }
 
+// NewBatcher uses the same fixed size and timeout for every resource,
+// see Configure for per resource and adaptive settings
 func NewBatcher(q queue.Queue, batchSize int, timeout time.Duration) *Batcher {
 	b := &Batcher{
-		queue:     q,
-		batchSize: batchSize,
-		timeout:   timeout,
-		users:     make([]*queue.Job, 0, batchSize),
-		products:  make([]*queue.Job, 0, batchSize),
-		orders:    make([]*queue.Job, 0, batchSize),
-		timer:     time.NewTimer(timeout),
-		stopCh:    make(chan struct{}),
+		queue:  q,
+		lanes:  make(map[queue.JobType]*lane),
+		stopCh: make(chan struct{}),
 	}
 
-	go b.run()
+	for _, resource := range []queue.JobType{queue.User, queue.Product, queue.Order} {
+		l := &lane{
+			resource: resource,
+			jobs:     make([]*queue.Job, 0, batchSize),
+			tuner:    newTuner(resource, Settings{Size: batchSize, Timeout: timeout}),
+			timer:    time.NewTimer(timeout),
+		}
+		b.lanes[resource] = l
+		go b.run(l)
+	}
 	return b
 }
@@ -0,0 +1,72 @@
+package batcher
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue/algorithms"
+)
+
+func TestBatcher_MaxBytes(t *testing.T) {
+	q := algorithms.NewFCFSQueue()
+	b := NewBatcher(q, 100, time.Hour)
+	defer b.Stop()
+	b.Configure(queue.User, Settings{Size: 100, Timeout: time.Hour, MaxBytes: 250})
+
+	big := `{"name":"` + strings.Repeat("x", 100) + `"}`
+	b.AddUser(job(0, queue.User, queue.Create, big))
+	b.AddUser(job(1, queue.User, queue.Create, big))
+	if pending := b.Pending()[queue.User]; pending != 2 {
+		t.Fatalf("Expected 2 jobs buffered under max bytes, got %d", pending)
+	}
+	b.AddUser(job(2, queue.User, queue.Create, big))
+	if pending := b.Pending()[queue.User]; pending != 0 {
+		t.Errorf("Expected max bytes to flush the batch, %d jobs left", pending)
+	}
+	if q.Len() != 3 {
+		t.Errorf("Expected 3 jobs queued, got %d", q.Len())
+	}
+}
+
+func TestBatcher_ConfigureResetsTimer(t *testing.T) {
+	q := algorithms.NewFCFSQueue()
+	b := NewBatcher(q, 100, time.Hour)
+	defer b.Stop()
+	b.Configure(queue.User, Settings{Size: 100, Timeout: 20 * time.Millisecond})
+
+	start := time.Now()
+	b.AddUser(job(0, queue.User, queue.Create, `{}`))
+	for b.Pending()[queue.User] > 0 {
+		if time.Since(start) > time.Second {
+			t.Fatalf("Expected the new timeout to flush the job, not the old one")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBatcher_MaxAge(t *testing.T) {
+	q := algorithms.NewFCFSQueue()
+	b := NewBatcher(q, 100, time.Hour)
+	defer b.Stop()
+	b.Configure(queue.Order, Settings{Size: 100, Timeout: time.Hour, MaxAge: 20 * time.Millisecond})
+
+	start := time.Now()
+	b.AddOrder(job(0, queue.Order, queue.Create, `{}`))
+	for b.Pending()[queue.Order] > 0 {
+		if time.Since(start) > time.Second {
+			t.Fatalf("Expected max age to flush the job")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if waited := time.Since(start); waited < 20*time.Millisecond {
+		t.Errorf("Expected the job to wait its max age, flushed after %v", waited)
+	}
+
+	// The timer starts over with the next job
+	b.AddOrder(job(1, queue.Order, queue.Create, `{}`))
+	if pending := b.Pending()[queue.Order]; pending != 1 {
+		t.Errorf("Expected the new job to wait, got %d pending", pending)
+	}
+}
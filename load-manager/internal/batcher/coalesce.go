@@ -0,0 +1,101 @@
+package batcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/metrics"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+)
+
+var supersededJobs = metrics.NewCounterVec("lm_batcher_superseded_total",
+	"Writes collapsed before dispatch by a later write to the same key", "resource", "operation")
+
+// The fields that identify a record, only the one for the job's resource is used
+type recordKey struct {
+	Email     string `json:"email"`
+	ProductID int64  `json:"product_id"`
+	OrderID   int64  `json:"order_id"`
+}
+
+// keyOf names the record a job touches, false when it has none, like a
+// product or order create, or a read that lists everything
+func keyOf(job *queue.Job) (string, bool) {
+	var key recordKey
+	if err := json.Unmarshal(job.Payload, &key); err != nil {
+		return "", false
+	}
+	switch {
+	case job.Resource == queue.User && key.Email != "":
+		return "user:" + key.Email, true
+	case job.Resource == queue.Product && key.ProductID != 0:
+		return fmt.Sprintf("product:%d", key.ProductID), true
+	case job.Resource == queue.Order && key.OrderID != 0:
+		return fmt.Sprintf("order:%d", key.OrderID), true
+	}
+	return "", false
+}
+
+/*
+collapse drops updates that a later update or delete of the same record
+makes pointless, kept jobs stay in order. Creates are never dropped: a
+create of a record that already exists fails, so cancelling it against a
+later delete would keep a record the delete should remove. A read of the
+record in between keeps both, a read listing everything keeps all.
+
+Kept jobs are still queued grouped by operation and the queue decides
+their order, see groupAndPush. Dropping only earlier updates gives the
+result of running the writes in order however the rest get reordered
+*/
+func collapse(jobs []*queue.Job) (kept, superseded []*queue.Job) {
+	drop := make([]bool, len(jobs))
+	last := make(map[string]int) // record -> index of its latest write
+
+	for i, job := range jobs {
+		key, ok := keyOf(job)
+		switch {
+		case job.CRUD == queue.Read && !ok:
+			clear(last)
+			continue
+		case job.CRUD == queue.Read:
+			delete(last, key)
+			continue
+		case !ok:
+			continue
+		}
+
+		prev, seen := last[key]
+		last[key] = i
+		if seen && jobs[prev].CRUD == queue.Update &&
+			(job.CRUD == queue.Update || job.CRUD == queue.Delete) {
+			drop[prev] = true
+		}
+	}
+
+	for i, job := range jobs {
+		if drop[i] {
+			superseded = append(superseded, job)
+		} else {
+			kept = append(kept, job)
+		}
+	}
+	return kept, superseded
+}
+
+// Superseded jobs are done, they are reported as such and never queued
+func (b *Batcher) coalesce(jobs []*queue.Job) []*queue.Job {
+	kept, superseded := collapse(jobs)
+	for _, job := range superseded {
+		supersededJobs.With(job.Resource.String(), job.CRUD.String()).Inc()
+		job.Supersede()
+	}
+	if len(superseded) > 0 {
+		ids := make([]int, len(superseded))
+		for i, job := range superseded {
+			ids[i] = job.ID
+		}
+		slog.Debug("Collapsed superseded writes", "job_ids", ids)
+	}
+	return kept
+}
@@ -0,0 +1,127 @@
+package batcher
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue/algorithms"
+)
+
+func job(id int, resource queue.JobType, crud queue.Operation, payload string) *queue.Job {
+	j := &queue.Job{ID: id, Resource: resource, CRUD: crud, Payload: []byte(payload)}
+	j.NewReply()
+	return j
+}
+
+func ids(jobs []*queue.Job) []int {
+	out := make([]int, len(jobs))
+	for i, j := range jobs {
+		out[i] = j.ID
+	}
+	return out
+}
+
+func TestCollapse(t *testing.T) {
+	tests := []struct {
+		name       string
+		jobs       []*queue.Job
+		kept       []int
+		superseded []int
+	}{
+		{
+			name: "last update wins",
+			jobs: []*queue.Job{
+				job(0, queue.User, queue.Update, `{"email":"a@x.io","name":"1"}`),
+				job(1, queue.User, queue.Update, `{"email":"b@x.io","name":"1"}`),
+				job(2, queue.User, queue.Update, `{"email":"a@x.io","name":"2"}`),
+			},
+			kept:       []int{1, 2},
+			superseded: []int{0},
+		},
+		{
+			name: "delete supersedes update",
+			jobs: []*queue.Job{
+				job(0, queue.Product, queue.Update, `{"product_id":7,"name":"n"}`),
+				job(1, queue.Product, queue.Delete, `{"product_id":7}`),
+			},
+			kept:       []int{1},
+			superseded: []int{0},
+		},
+		{
+			name: "creates are never dropped",
+			jobs: []*queue.Job{
+				job(0, queue.User, queue.Create, `{"email":"a@x.io"}`),
+				job(1, queue.User, queue.Update, `{"email":"a@x.io"}`),
+				job(2, queue.User, queue.Delete, `{"email":"a@x.io"}`),
+				job(3, queue.User, queue.Create, `{"email":"a@x.io"}`),
+			},
+			kept:       []int{0, 2, 3},
+			superseded: []int{1},
+		},
+		{
+			name: "read in between keeps both",
+			jobs: []*queue.Job{
+				job(0, queue.Order, queue.Update, `{"order_id":3,"quantity":1}`),
+				job(1, queue.Order, queue.Read, `{"order_id":3}`),
+				job(2, queue.Order, queue.Update, `{"order_id":3,"quantity":2}`),
+				job(3, queue.Order, queue.Read, `{"user_id":1}`),
+				job(4, queue.Order, queue.Update, `{"order_id":3,"quantity":3}`),
+			},
+			kept:       []int{0, 1, 2, 3, 4},
+			superseded: nil,
+		},
+		{
+			name: "creates without an id are left alone",
+			jobs: []*queue.Job{
+				job(0, queue.Order, queue.Create, `{"user_id":1,"product_id":1,"quantity":1}`),
+				job(1, queue.Order, queue.Create, `{"user_id":1,"product_id":1,"quantity":1}`),
+			},
+			kept:       []int{0, 1},
+			superseded: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, superseded := collapse(tt.jobs)
+			if !slices.Equal(ids(kept), tt.kept) || !slices.Equal(ids(superseded), tt.superseded) {
+				t.Errorf("Expected kept %v superseded %v, got %v %v",
+					tt.kept, tt.superseded, ids(kept), ids(superseded))
+			}
+		})
+	}
+}
+
+func TestCoalesce_ReportsSuperseded(t *testing.T) {
+	b := &Batcher{}
+	first := job(0, queue.User, queue.Update, `{"email":"a@x.io"}`)
+	second := job(1, queue.User, queue.Update, `{"email":"a@x.io"}`)
+
+	if kept := b.coalesce([]*queue.Job{first, second}); len(kept) != 1 || kept[0] != second {
+		t.Fatalf("Expected only the last update kept, got %v", ids(kept))
+	}
+	if r := <-first.Reply; !r.Superseded || r.Err != nil {
+		t.Errorf("Expected the first update reported superseded, got %+v", r)
+	}
+}
+
+// What reaches the queue: superseded updates are gone, the rest is grouped
+// by operation, arrival order is only kept within an operation
+func TestGroupAndPush_Coalesced(t *testing.T) {
+	q := algorithms.NewFCFSQueue()
+	b := &Batcher{queue: q}
+	b.SetCoalesceWrites(true)
+	b.groupAndPush([]*queue.Job{
+		job(0, queue.User, queue.Delete, `{"email":"a@x.io"}`),
+		job(1, queue.User, queue.Update, `{"email":"b@x.io","name":"1"}`),
+		job(2, queue.User, queue.Create, `{"email":"a@x.io"}`),
+		job(3, queue.User, queue.Update, `{"email":"b@x.io","name":"2"}`),
+		job(4, queue.User, queue.Create, `{"email":"c@x.io"}`),
+	})
+
+	popped, _ := q.Pops()
+	if got := ids(popped); !slices.Equal(got, []int{2, 4, 3, 0}) {
+		t.Errorf("Expected creates, updates then deletes [2 4 3 0], got %v", got)
+	}
+}
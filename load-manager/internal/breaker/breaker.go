@@ -0,0 +1,214 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+var ErrOpen = errors.New("circuit breaker is open")
+
+type Config struct {
+	FailureThreshold int           // consecutive failures before opening
+	OpenTimeout      time.Duration // time spent open before probing
+	HalfOpenProbes   int           // probes let through while half-open
+}
+
+func DefaultConfig() Config {
+	return Config{
+		FailureThreshold: 5,
+		OpenTimeout:      5 * time.Second,
+		HalfOpenProbes:   1,
+	}
+}
+
+type Stats struct {
+	State    State
+	Failures int
+	OpenedAt time.Time
+}
+
+/*
+Breaker is a closed/open/half-open circuit breaker.
+Closed lets everything through and counts consecutive failures,
+Open fails fast until OpenTimeout passes, then HalfOpen lets
+HalfOpenProbes calls through; if they all succeed it closes again,
+any failure opens it again.
+*/
+type Breaker struct {
+	conf      Config
+	state     State
+	failures  int
+	probes    int // probes in flight while half-open
+	successes int // successful probes while half-open
+	openedAt  time.Time
+	onChange  func(from, to State)
+	mutex     sync.Mutex
+}
+
+// OnChange registers fn to be called on every state transition.
+// fn runs with the breaker locked and must not call back into it.
+func (b *Breaker) OnChange(fn func(from, to State)) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.onChange = fn
+}
+
+// Must hold lock
+func (b *Breaker) setStateLocked(to State) {
+	from := b.state
+	b.state = to
+	if from != to && b.onChange != nil {
+		b.onChange(from, to)
+	}
+}
+
+// Must hold lock
+func (b *Breaker) advanceLocked(now time.Time) {
+	if b.state == Open && now.Sub(b.openedAt) >= b.conf.OpenTimeout {
+		b.setStateLocked(HalfOpen)
+		b.probes = 0
+		b.successes = 0
+	}
+}
+
+// Must hold lock
+func (b *Breaker) openLocked(now time.Time) {
+	b.setStateLocked(Open)
+	b.openedAt = now
+	b.probes = 0
+	b.successes = 0
+}
+
+// Ready reports whether a call would be let through, without taking a probe slot.
+// Selectors use it to skip nodes.
+func (b *Breaker) Ready() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.advanceLocked(time.Now())
+	switch b.state {
+	case Open:
+		return false
+	case HalfOpen:
+		return b.probes < b.conf.HalfOpenProbes
+	}
+	return true
+}
+
+// Allow must be called before each call, and followed by Success, Failure or Ignore
+// when it returns nil.
+func (b *Breaker) Allow() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.advanceLocked(time.Now())
+	switch b.state {
+	case Open:
+		return ErrOpen
+	case HalfOpen:
+		if b.probes >= b.conf.HalfOpenProbes {
+			return ErrOpen
+		}
+		b.probes++
+	}
+	return nil
+}
+
+func (b *Breaker) Success() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.state {
+	case Closed:
+		b.failures = 0
+	case HalfOpen:
+		b.probes--
+		b.successes++
+		if b.successes >= b.conf.HalfOpenProbes {
+			b.setStateLocked(Closed)
+			b.failures = 0
+		}
+	}
+}
+
+// Ignore ends a call that says nothing about the node, like one its
+// client gave up on, freeing its probe slot while half-open
+func (b *Breaker) Ignore() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.state == HalfOpen {
+		b.probes--
+	}
+}
+
+func (b *Breaker) Failure() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	switch b.state {
+	case Closed:
+		b.failures++
+		if b.failures >= b.conf.FailureThreshold {
+			b.openLocked(now)
+		}
+	case HalfOpen:
+		b.failures++
+		b.openLocked(now)
+	}
+}
+
+func (b *Breaker) State() State {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.advanceLocked(time.Now())
+	return b.state
+}
+
+func (b *Breaker) Stats() Stats {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.advanceLocked(time.Now())
+	return Stats{
+		State:    b.state,
+		Failures: b.failures,
+		OpenedAt: b.openedAt,
+	}
+}
+
+func NewBreaker(conf Config) *Breaker {
+	if conf.FailureThreshold < 1 {
+		conf.FailureThreshold = 1
+	}
+	if conf.HalfOpenProbes < 1 {
+		conf.HalfOpenProbes = 1
+	}
+	return &Breaker{
+		conf:  conf,
+		state: Closed,
+	}
+}
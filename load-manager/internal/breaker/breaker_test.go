@@ -0,0 +1,86 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker_OpensAfterThreshold(t *testing.T) {
+	b := NewBreaker(Config{FailureThreshold: 3, OpenTimeout: time.Hour, HalfOpenProbes: 1})
+
+	for range 2 {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("Closed breaker not supposed to reject %v", err)
+		}
+		b.Failure()
+	}
+	if b.State() != Closed {
+		t.Errorf("Expected closed after 2 failures, got %v", b.State())
+	}
+
+	b.Failure()
+	if b.State() != Open {
+		t.Errorf("Expected open after 3 failures, got %v", b.State())
+	}
+	if b.Ready() {
+		t.Errorf("Open breaker must not be ready")
+	}
+	if err := b.Allow(); err != ErrOpen {
+		t.Errorf("Open breaker must fail fast, got %v", err)
+	}
+}
+
+func TestBreaker_HalfOpenProbes(t *testing.T) {
+	b := NewBreaker(Config{FailureThreshold: 1, OpenTimeout: 10 * time.Millisecond, HalfOpenProbes: 2})
+
+	b.Failure()
+	time.Sleep(20 * time.Millisecond)
+
+	if b.State() != HalfOpen {
+		t.Fatalf("Expected half-open after timeout, got %v", b.State())
+	}
+
+	// Only 2 probes at a time
+	for range 2 {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("Half-open breaker must let probes through %v", err)
+		}
+	}
+	if err := b.Allow(); err != ErrOpen {
+		t.Errorf("Half-open breaker let too many probes through")
+	}
+
+	b.Success()
+	b.Success()
+	if b.State() != Closed {
+		t.Errorf("Expected closed after successful probes, got %v", b.State())
+	}
+
+	// Failed probe opens again
+	b.Failure()
+	time.Sleep(20 * time.Millisecond)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Expected probe through %v", err)
+	}
+	b.Failure()
+	if b.State() != Open {
+		t.Errorf("Failed probe must reopen breaker, got %v", b.State())
+	}
+}
+
+func TestBreaker_IgnoreFreesProbe(t *testing.T) {
+	b := NewBreaker(Config{FailureThreshold: 1, OpenTimeout: 10 * time.Millisecond, HalfOpenProbes: 1})
+
+	b.Failure()
+	time.Sleep(20 * time.Millisecond)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Expected probe through %v", err)
+	}
+	b.Ignore()
+	if b.State() != HalfOpen {
+		t.Errorf("Expected an ignored probe to leave the breaker half-open, got %v", b.State())
+	}
+	if err := b.Allow(); err != nil {
+		t.Errorf("Expected the ignored probe's slot free, got %v", err)
+	}
+}
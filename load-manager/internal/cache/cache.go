@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/metrics"
+)
+
+var (
+	requestsTotal = metrics.NewCounterVec("lm_cache_requests_total",
+		"Read cache lookups by resource and result, hit or miss", "resource", "result")
+	invalidationsTotal = metrics.NewCounterVec("lm_cache_invalidations_total",
+		"Writes that invalidated cached reads", "resource")
+	entriesGauge = metrics.NewGaugeVec("lm_cache_entries",
+		"Read responses held in the cache")
+)
+
+type entryKey struct {
+	resource string
+	query    string
+}
+
+type entry struct {
+	key     entryKey
+	value   any
+	expires time.Time
+}
+
+/*
+Cache holds read responses by resource and query, least recently used
+entries go first once Size is reached and each lives at most TTL. Writes
+invalidate every entry of their resource, since a write to one user can
+change any list that includes it.
+
+Every resource has a generation bumped on invalidation. A read takes the
+generation before calling the backend and Put drops its answer when a
+write came in meanwhile, so an answer from before the write is never kept
+*/
+type Cache struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mutex       sync.Mutex
+	lru         *list.List // front is most recent, of *entry
+	entries     map[entryKey]*list.Element
+	generations map[string]uint64
+}
+
+func (c *Cache) Get(resource, query string) (any, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	elem, ok := c.entries[entryKey{resource, query}]
+	if ok && c.now().After(elem.Value.(*entry).expires) {
+		c.removeLocked(elem)
+		ok = false
+	}
+	if !ok {
+		requestsTotal.With(resource, "miss").Inc()
+		return nil, false
+	}
+	requestsTotal.With(resource, "hit").Inc()
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*entry).value, true
+}
+
+// Generation to pass to Put, taken before the backend call
+func (c *Cache) Generation(resource string) uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.generations[resource]
+}
+
+// Put stores value unless resource was invalidated since generation
+func (c *Cache) Put(resource, query string, generation uint64, value any) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.generations[resource] != generation {
+		return
+	}
+	key := entryKey{resource, query}
+	if elem, ok := c.entries[key]; ok {
+		c.removeLocked(elem)
+	}
+	c.entries[key] = c.lru.PushFront(&entry{
+		key:     key,
+		value:   value,
+		expires: c.now().Add(c.ttl),
+	})
+	for c.lru.Len() > c.size {
+		c.removeLocked(c.lru.Back())
+	}
+	entriesGauge.With().Set(float64(c.lru.Len()))
+}
+
+// Invalidate drops every entry of resource
+func (c *Cache) Invalidate(resource string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.generations[resource]++
+	for key, elem := range c.entries {
+		if key.resource == resource {
+			c.removeLocked(elem)
+		}
+	}
+	invalidationsTotal.With(resource).Inc()
+}
+
+func (c *Cache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.lru.Len()
+}
+
+// Must hold lock
+func (c *Cache) removeLocked(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*entry).key)
+	entriesGauge.With().Set(float64(c.lru.Len()))
+}
+
+func NewCache(size int, ttl time.Duration) *Cache {
+	entriesGauge.With().Set(0)
+	return &Cache{
+		size:        size,
+		ttl:         ttl,
+		now:         time.Now,
+		lru:         list.New(),
+		entries:     make(map[entryKey]*list.Element),
+		generations: make(map[string]uint64),
+	}
+}
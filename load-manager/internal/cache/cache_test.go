@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func fakeClock(c *Cache) *time.Time {
+	now := time.Unix(1000, 0)
+	c.now = func() time.Time { return now }
+	return &now
+}
+
+func TestCache_LRU(t *testing.T) {
+	c := NewCache(2, time.Minute)
+	c.Put("user", "a", 0, 1)
+	c.Put("user", "b", 0, 2)
+	c.Get("user", "a")
+	c.Put("user", "c", 0, 3)
+
+	if _, ok := c.Get("user", "b"); ok {
+		t.Errorf("Expected least recently used entry to be evicted")
+	}
+	if v, ok := c.Get("user", "a"); !ok || v != 1 {
+		t.Errorf("Expected a to stay cached, got %v %v", v, ok)
+	}
+}
+
+func TestCache_TTL(t *testing.T) {
+	c := NewCache(10, time.Second)
+	now := fakeClock(c)
+	c.Put("product", "", 0, "all")
+
+	*now = now.Add(500 * time.Millisecond)
+	if _, ok := c.Get("product", ""); !ok {
+		t.Fatalf("Expected a hit before the TTL")
+	}
+	*now = now.Add(time.Second)
+	if _, ok := c.Get("product", ""); ok || c.Len() != 0 {
+		t.Errorf("Expected expired entry to be dropped")
+	}
+}
+
+func TestCache_Invalidate(t *testing.T) {
+	c := NewCache(10, time.Minute)
+	gen := c.Generation("user")
+	c.Put("user", "a", gen, 1)
+	c.Put("order", "a", c.Generation("order"), 2)
+
+	c.Invalidate("user")
+	if _, ok := c.Get("user", "a"); ok {
+		t.Errorf("Expected user entries to be invalidated")
+	}
+	if _, ok := c.Get("order", "a"); !ok {
+		t.Errorf("Expected other resources to stay cached")
+	}
+
+	// A read that started before the write must not be kept
+	c.Put("user", "a", gen, 1)
+	if _, ok := c.Get("user", "a"); ok {
+		t.Errorf("Expected stale generation to be dropped")
+	}
+}
@@ -0,0 +1,205 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrLimited is returned when a slot did not free up before the deadline
+var ErrLimited = errors.New("concurrency limit reached")
+
+// Algorithms
+const (
+	None     = ""         // unbounded, calls are only counted
+	AIMD     = "aimd"     // +1 per full window, times Backoff on overload
+	Gradient = "gradient" // follows the ratio of long term to current latency
+)
+
+type Config struct {
+	Algorithm string
+	Initial   int
+	Min       int
+	Max       int
+	Backoff   float64 // AIMD multiplier on overload, and gradient's floor
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Algorithm: None,
+		Initial:   20,
+		Min:       1,
+		Max:       200,
+		Backoff:   0.9,
+	}
+}
+
+func ParseAlgorithm(name string) (string, error) {
+	switch name {
+	case None, AIMD, Gradient:
+		return name, nil
+	}
+	return "", fmt.Errorf("invalid concurrency algorithm %s. Must be: aimd, gradient", name)
+}
+
+// Outcome of a call, decides how it moves the limit
+type Outcome int
+
+const (
+	Success Outcome = iota // latency is a sample, backend errors like not found count
+	Dropped                // overload, timeouts and ResourceExhausted
+	Ignored                // says nothing about load, e.g. the node is down
+)
+
+// Gradient tuning, as in Netflix concurrency-limits Gradient2
+const (
+	longWindow = 600 // samples averaged into the long term latency
+	tolerance  = 1.5 // latency growth allowed before the limit shrinks
+	smoothing  = 0.2
+)
+
+/*
+Limiter caps calls in flight to one node. The limit starts at Initial and
+moves between Min and Max as calls finish. AIMD adds one while the window
+is in use and multiplies by Backoff on overload. Gradient compares each
+latency to a long term average and shrinks the limit as latency grows,
+with sqrt(limit) of headroom so it can find more capacity. Limits only
+grow while at least half the window is in use
+*/
+type Limiter struct {
+	conf Config
+
+	mutex    sync.Mutex
+	limit    float64
+	inFlight int
+	longRtt  time.Duration // gradient only, 0 until the first sample
+	freed    chan struct{} // closed and replaced when a slot frees up
+}
+
+func (l *Limiter) Enabled() bool {
+	return l.conf.Algorithm != None
+}
+
+// Limit is the current cap, 0 when unbounded
+func (l *Limiter) Limit() int {
+	if !l.Enabled() {
+		return 0
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return int(l.limit)
+}
+
+func (l *Limiter) InFlight() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.inFlight
+}
+
+// Free reports whether a call would start without waiting
+func (l *Limiter) Free() bool {
+	if !l.Enabled() {
+		return true
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.inFlight < int(l.limit)
+}
+
+// Acquire waits for a slot until ctx is done, every Acquire needs a Release
+func (l *Limiter) Acquire(ctx context.Context) error {
+	for {
+		l.mutex.Lock()
+		if !l.Enabled() || l.inFlight < int(l.limit) {
+			l.inFlight++
+			l.mutex.Unlock()
+			return nil
+		}
+		freed := l.freed
+		l.mutex.Unlock()
+
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return ErrLimited
+		}
+	}
+}
+
+// Release frees the slot and feeds the call's latency to the algorithm
+func (l *Limiter) Release(rtt time.Duration, outcome Outcome) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.inFlight--
+	if outcome != Ignored {
+		switch l.conf.Algorithm {
+		case AIMD:
+			l.aimdLocked(outcome)
+		case Gradient:
+			l.gradientLocked(rtt, outcome)
+		}
+	}
+
+	close(l.freed)
+	l.freed = make(chan struct{})
+}
+
+// Nothing learned about the ceiling while most of the window sits idle
+func (l *Limiter) appLimitedLocked() bool {
+	return float64(l.inFlight+1)*2 < l.limit
+}
+
+func (l *Limiter) setLimitLocked(limit float64) {
+	l.limit = min(float64(l.conf.Max), max(float64(l.conf.Min), limit))
+}
+
+func (l *Limiter) aimdLocked(outcome Outcome) {
+	switch {
+	case outcome == Dropped:
+		l.setLimitLocked(l.limit * l.conf.Backoff)
+	case !l.appLimitedLocked():
+		// +1 per window's worth of successes
+		l.setLimitLocked(l.limit + 1/l.limit)
+	}
+}
+
+func (l *Limiter) gradientLocked(rtt time.Duration, outcome Outcome) {
+	if outcome == Dropped {
+		l.setLimitLocked(l.limit * l.conf.Backoff)
+		return
+	}
+	if rtt <= 0 {
+		return
+	}
+	if l.longRtt == 0 {
+		l.longRtt = rtt
+	}
+	alpha := 2.0 / (longWindow + 1)
+	l.longRtt = time.Duration(float64(l.longRtt)*(1-alpha) + float64(rtt)*alpha)
+
+	// Latency well under the average drags it down, so a slow spell is not the new normal
+	if ratio := float64(l.longRtt) / float64(rtt); ratio > 2 {
+		l.longRtt = time.Duration(float64(l.longRtt) * 0.95)
+	}
+
+	gradient := max(l.conf.Backoff, min(1, tolerance*float64(l.longRtt)/float64(rtt)))
+	next := l.limit*gradient + math.Sqrt(l.limit)
+	next = l.limit*(1-smoothing) + next*smoothing
+	if next > l.limit && l.appLimitedLocked() {
+		return
+	}
+	l.setLimitLocked(next)
+}
+
+func NewLimiter(conf Config) *Limiter {
+	l := &Limiter{
+		conf:  conf,
+		freed: make(chan struct{}),
+	}
+	l.setLimitLocked(float64(conf.Initial))
+	return l
+}
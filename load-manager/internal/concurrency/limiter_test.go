@@ -0,0 +1,107 @@
+package concurrency
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiter_WaitsForSlot(t *testing.T) {
+	l := NewLimiter(Config{Algorithm: AIMD, Initial: 2, Min: 1, Max: 10, Backoff: 0.5})
+	ctx := context.Background()
+
+	for range 2 {
+		if err := l.Acquire(ctx); err != nil {
+			t.Fatalf("Expected a free slot, got %v", err)
+		}
+	}
+	if l.Free() {
+		t.Errorf("Expected limiter to be full")
+	}
+
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := l.Acquire(short); err != ErrLimited {
+		t.Fatalf("Expected ErrLimited past the deadline, got %v", err)
+	}
+
+	done := make(chan error)
+	go func() { done <- l.Acquire(ctx) }()
+	l.Release(time.Millisecond, Ignored)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected waiter to get the freed slot, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Waiter never woke up")
+	}
+}
+
+func TestLimiter_AIMD(t *testing.T) {
+	l := NewLimiter(Config{Algorithm: AIMD, Initial: 4, Min: 1, Max: 10, Backoff: 0.5})
+	ctx := context.Background()
+
+	// About a window of successes with the window full adds one
+	for range 3 {
+		l.Acquire(ctx)
+	}
+	for range 5 {
+		l.Acquire(ctx)
+		l.Release(time.Millisecond, Success)
+	}
+	if l.Limit() != 5 {
+		t.Errorf("Expected limit 5 after a window of successes, got %d", l.Limit())
+	}
+
+	l.Acquire(ctx)
+	l.Release(time.Millisecond, Dropped)
+	if l.Limit() != 2 {
+		t.Errorf("Expected limit halved on overload, got %d", l.Limit())
+	}
+
+	// Never below Min
+	for range 3 {
+		l.Release(time.Millisecond, Dropped)
+	}
+	if l.Limit() != 1 {
+		t.Errorf("Expected limit at min, got %d", l.Limit())
+	}
+}
+
+func TestLimiter_GradientShrinksOnLatency(t *testing.T) {
+	l := NewLimiter(Config{Algorithm: Gradient, Initial: 20, Min: 1, Max: 100, Backoff: 0.5})
+	ctx := context.Background()
+
+	// Keep the window busy so the limit may move
+	for range 15 {
+		l.Acquire(ctx)
+	}
+	for range 50 {
+		l.Acquire(ctx)
+		l.Release(10*time.Millisecond, Success)
+	}
+	steady := l.Limit()
+	if steady < 20 {
+		t.Errorf("Expected the limit to grow at steady latency, got %d", steady)
+	}
+
+	for range 5 {
+		l.Release(100*time.Millisecond, Success)
+	}
+	if l.Limit() >= steady {
+		t.Errorf("Expected the limit to shrink as latency grows, was %d now %d", steady, l.Limit())
+	}
+}
+
+func TestLimiter_None(t *testing.T) {
+	l := NewLimiter(DefaultConfig())
+	for range 1000 {
+		if err := l.Acquire(context.Background()); err != nil {
+			t.Fatalf("Unbounded limiter must not block, got %v", err)
+		}
+	}
+	if l.Limit() != 0 || !l.Free() || l.InFlight() != 1000 {
+		t.Errorf("Expected unbounded limiter to only count, limit %d in flight %d", l.Limit(), l.InFlight())
+	}
+}
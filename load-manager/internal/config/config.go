@@ -0,0 +1,608 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/concurrency"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/discovery"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/proxy"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue/algorithms"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/ratelimit"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/selector"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/strategy"
+)
+
+/*
+Load manager config, lowest to highest precedence: defaults, --config
+file, LM_* environment variables, command line flags
+
+	mode: batch
+	port: 8000
+	grpc_port: 7000
+	log_level: info
+	nodes:
+	  - host: localhost
+	    port: 50001
+	    weight: 2
+	queue:
+	  algorithm: FCFS
+	selector: RR
+	strategy: M
+	batch:
+	  size: 100
+	  timeout_ms: 2
+	  coalesce_writes: true
+	  max_bytes: 1048576
+	  max_age_ms: 20
+	  adaptive: true
+	  min_size: 10
+	  max_size: 1000
+	  resources:
+	    order:
+	      size: 20
+	      max_timeout_ms: 5
+	workers: 4
+	breaker:
+	  failures: 5
+	  timeout_ms: 5000
+	  probes: 1
+	timeouts:
+	  request_ms: 5000
+	  drain_ms: 10000
+	  shutdown_ms: 5000
+	retry:
+	  attempts: 2
+	  backoff_ms: 50
+	proxy:
+	  http_port_offset: -41000
+	  max_body_bytes: 1048576
+	backend_tls:
+	  ca: certs/ca.pem
+	  cert: certs/client.pem
+	  key: certs/client-key.pem
+	tls:
+	  cert: certs/lm.pem
+	  key: certs/lm-key.pem
+	auth:
+	  keys_file: keys.yaml
+	rate_limit:
+	  rate: 50
+	  burst: 100
+	  routes:
+	    - method: POST
+	      resource: order
+	      rate: 5
+	admission:
+	  target_ms: 5
+	  interval_ms: 100
+	  shed_below: 1
+	  max_priority: 0
+	concurrency:
+	  algorithm: gradient
+	  initial: 20
+	  max: 200
+	hedge:
+	  percentile: 95
+	  min_delay_ms: 2
+	cache:
+	  size: 10000
+	  ttl_ms: 1000
+*/
+type Config struct {
+	Mode          string               `yaml:"mode"`
+	Port          int                  `yaml:"port"`
+	GRPCPort      int                  `yaml:"grpc_port"` // gRPC front door, 0 disables
+	LogLevel      string               `yaml:"log_level"`
+	Nodes         []discovery.FileNode `yaml:"nodes"`
+	NodesFile     string               `yaml:"nodes_file"`
+	NodesReloadMs int                  `yaml:"nodes_reload_ms"`
+	Queue         QueueConfig          `yaml:"queue"`
+	Selector      string               `yaml:"selector"`
+	Strategy      string               `yaml:"strategy"`
+	Batch         BatchConfig          `yaml:"batch"`
+	Workers       int                  `yaml:"workers"`
+	Breaker       BreakerConfig        `yaml:"breaker"`
+	Timeouts      TimeoutsConfig       `yaml:"timeouts"`
+	Retry         RetryConfig          `yaml:"retry"`
+	SlowStartMs   int                  `yaml:"slow_start_ms"`
+	TraceFile     string               `yaml:"trace_file"`
+	Proxy         ProxyConfig          `yaml:"proxy"`
+	BackendTLS    BackendTLSConfig     `yaml:"backend_tls"`
+	TLS           TLSConfig            `yaml:"tls"`
+	Auth          AuthConfig           `yaml:"auth"`
+	RateLimit     RateLimitConfig      `yaml:"rate_limit"`
+	Admission     AdmissionConfig      `yaml:"admission"`
+	Concurrency   ConcurrencyConfig    `yaml:"concurrency"`
+	Hedge         HedgeConfig          `yaml:"hedge"`
+	Cache         CacheConfig          `yaml:"cache"`
+}
+
+// Modes, batch queues and batches requests over gRPC, proxy forwards
+// each /single request to a backend's HTTP server
+const (
+	ModeBatch = "batch"
+	ModeProxy = "proxy"
+)
+
+type QueueConfig struct {
+	Algorithm string `yaml:"algorithm"`
+}
+
+/*
+Batch settings for every resource, resources overrides them for user,
+product or order, fields left out or 0 are inherited. Adaptive batches
+move between the min and max bounds, see batcher.Settings
+*/
+type BatchConfig struct {
+	BatchSettings  `yaml:",inline"`
+	CoalesceWrites bool                     `yaml:"coalesce_writes"` // drop writes a later one in the batch supersedes
+	Resources      map[string]BatchSettings `yaml:"resources"`
+}
+
+type BatchSettings struct {
+	Size         int  `yaml:"size"`
+	TimeoutMs    int  `yaml:"timeout_ms"`
+	Adaptive     bool `yaml:"adaptive"` // on for all resources or just this one
+	MinSize      int  `yaml:"min_size"`
+	MaxSize      int  `yaml:"max_size"`
+	MinTimeoutMs int  `yaml:"min_timeout_ms"`
+	MaxTimeoutMs int  `yaml:"max_timeout_ms"`
+	MaxBytes     int  `yaml:"max_bytes"`  // flush at this much payload, 0 is off
+	MaxAgeMs     int  `yaml:"max_age_ms"` // flush once the oldest job waited this long, 0 is off
+}
+
+// For merges the overrides for resource over the shared settings
+func (b BatchConfig) For(resource string) BatchSettings {
+	s := b.BatchSettings
+	o := b.Resources[resource]
+	override := func(field *int, value int) {
+		if value != 0 {
+			*field = value
+		}
+	}
+	override(&s.Size, o.Size)
+	override(&s.TimeoutMs, o.TimeoutMs)
+	override(&s.MinSize, o.MinSize)
+	override(&s.MaxSize, o.MaxSize)
+	override(&s.MinTimeoutMs, o.MinTimeoutMs)
+	override(&s.MaxTimeoutMs, o.MaxTimeoutMs)
+	override(&s.MaxBytes, o.MaxBytes)
+	override(&s.MaxAgeMs, o.MaxAgeMs)
+	s.Adaptive = s.Adaptive || o.Adaptive
+	return s
+}
+
+type BreakerConfig struct {
+	Failures  int `yaml:"failures"`
+	TimeoutMs int `yaml:"timeout_ms"`
+	Probes    int `yaml:"probes"`
+}
+
+type TimeoutsConfig struct {
+	RequestMs  int `yaml:"request_ms"`  // per gRPC attempt
+	DrainMs    int `yaml:"drain_ms"`    // in-flight calls when removing a node
+	ShutdownMs int `yaml:"shutdown_ms"` // HTTP server graceful shutdown
+}
+
+// Attempts counts the first call, 1 disables retries
+type RetryConfig struct {
+	Attempts  int `yaml:"attempts"`
+	BackoffMs int `yaml:"backoff_ms"`
+}
+
+// A node's http_port label takes precedence over the offset
+type ProxyConfig struct {
+	HTTPPortOffset int `yaml:"http_port_offset"` // added to the gRPC port
+	MaxBodyBytes   int `yaml:"max_body_bytes"`   // larger requests get 413
+}
+
+/*
+TLS to the backends, on when ca or cert is set. ca verifies the backends,
+the system roots are used without it, cert and key are presented for
+mutual TLS. Files are checked for changes every reload_ms
+*/
+type BackendTLSConfig struct {
+	CA         string `yaml:"ca"`
+	Cert       string `yaml:"cert"`
+	Key        string `yaml:"key"`
+	ServerName string `yaml:"server_name"` // checked instead of the node host
+	ReloadMs   int    `yaml:"reload_ms"`
+}
+
+func (t BackendTLSConfig) Enabled() bool {
+	return t.CA != "" || t.Cert != ""
+}
+
+// HTTPS on port and TLS on grpc_port, on when cert is set
+type TLSConfig struct {
+	Cert     string `yaml:"cert"`
+	Key      string `yaml:"key"`
+	ReloadMs int    `yaml:"reload_ms"`
+}
+
+// API keys for /balancer and the gRPC front door, see internal/auth
+type AuthConfig struct {
+	KeysFile string `yaml:"keys_file"` // empty disables auth
+	ReloadMs int    `yaml:"reload_ms"`
+}
+
+// Requests per second per client, by API key or IP. Routes are checked
+// first, rate applies to the rest, 0 leaves them unlimited
+type RateLimitConfig struct {
+	Rate   float64          `yaml:"rate"`
+	Burst  int              `yaml:"burst"` // defaults to rate
+	Routes []ratelimit.Rule `yaml:"routes"`
+}
+
+/*
+CoDel on queue sojourn time, see internal/admission. Once every batch has
+waited over target_ms for interval_ms, jobs are dropped from the head and
+arrivals below shed_below priority are turned away. target_ms 0 disables.
+Clients without an API key can ask for at most max_priority
+*/
+type AdmissionConfig struct {
+	TargetMs    int `yaml:"target_ms"`
+	IntervalMs  int `yaml:"interval_ms"`
+	ShedBelow   int `yaml:"shed_below"`
+	MaxPriority int `yaml:"max_priority"`
+}
+
+/*
+Adaptive cap on gRPC calls in flight per node, see internal/concurrency.
+algorithm is aimd or gradient, empty leaves calls unbounded. backoff is
+how much of the limit is kept on overload
+*/
+type ConcurrencyConfig struct {
+	Algorithm string  `yaml:"algorithm"`
+	Initial   int     `yaml:"initial"`
+	Min       int     `yaml:"min"`
+	Max       int     `yaml:"max"`
+	Backoff   float64 `yaml:"backoff"`
+}
+
+// Reads still waiting past percentile of recent latency go to a second
+// node as well, the first answer wins. percentile 0 disables
+type HedgeConfig struct {
+	Percentile float64 `yaml:"percentile"`
+	MinDelayMs int     `yaml:"min_delay_ms"` // never hedge sooner
+}
+
+// Backend read responses by resource and query, writes to a resource drop
+// its entries. size is in entries, 0 disables
+type CacheConfig struct {
+	Size  int `yaml:"size"`
+	TTLMs int `yaml:"ttl_ms"`
+}
+
+func Default() Config {
+	return Config{
+		Mode:          ModeBatch,
+		Port:          8000,
+		LogLevel:      "info",
+		NodesReloadMs: 1000,
+		Queue:         QueueConfig{Algorithm: "FCFS"},
+		Selector:      "RR",
+		Strategy:      "M",
+		Batch: BatchConfig{BatchSettings: BatchSettings{
+			Size: 100, TimeoutMs: 2,
+			MinSize: 1, MaxSize: 1000, MinTimeoutMs: 1, MaxTimeoutMs: 10,
+		}},
+		Workers:     4,
+		Breaker:     BreakerConfig{Failures: 5, TimeoutMs: 5000, Probes: 1},
+		Timeouts:    TimeoutsConfig{RequestMs: 5000, DrainMs: 10000, ShutdownMs: 5000},
+		Retry:       RetryConfig{Attempts: 1, BackoffMs: 50},
+		Proxy:       ProxyConfig{HTTPPortOffset: proxy.DefaultPortOffset, MaxBodyBytes: proxy.DefaultMaxBodyBytes},
+		BackendTLS:  BackendTLSConfig{ReloadMs: 1000},
+		TLS:         TLSConfig{ReloadMs: 1000},
+		Auth:        AuthConfig{ReloadMs: 1000},
+		Admission:   AdmissionConfig{IntervalMs: 100, ShedBelow: 1},
+		Concurrency: ConcurrencyConfig{Initial: 20, Min: 1, Max: 200, Backoff: 0.9},
+		Hedge:       HedgeConfig{MinDelayMs: 1},
+		Cache:       CacheConfig{TTLMs: 1000},
+	}
+}
+
+// Parse reads YAML over the defaults, unknown fields are an error
+func Parse(data []byte) (Config, error) {
+	conf := Default()
+	if err := yaml.UnmarshalWithOptions(data, &conf, yaml.Strict()); err != nil {
+		return conf, err
+	}
+	return conf, nil
+}
+
+func Load(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Default(), err
+	}
+	return Parse(data)
+}
+
+// ParseAddr reads host:port, as given to --address
+func ParseAddr(addr string) (discovery.FileNode, error) {
+	parts := strings.Split(addr, ":")
+	if len(parts) != 2 {
+		return discovery.FileNode{}, fmt.Errorf("invalid address format %s. Expected host:port", addr)
+	}
+
+	port, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return discovery.FileNode{}, fmt.Errorf("invalid port in %s", addr)
+	}
+	return discovery.FileNode{Host: parts[0], Port: port}, nil
+}
+
+func ParseAddrs(addrs []string) ([]discovery.FileNode, error) {
+	nodes := make([]discovery.FileNode, 0, len(addrs))
+	for _, addr := range addrs {
+		node, err := ParseAddr(addr)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+func envString(name string, field *string) error {
+	if v, ok := os.LookupEnv(name); ok {
+		*field = v
+	}
+	return nil
+}
+
+func envInt(name string, field *int) error {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("%s: not an integer %q", name, v)
+	}
+	*field = n
+	return nil
+}
+
+func envBool(name string, field *bool) error {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("%s: not a boolean %q", name, v)
+	}
+	*field = b
+	return nil
+}
+
+func envFloat(name string, field *float64) error {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return fmt.Errorf("%s: not a number %q", name, v)
+	}
+	*field = f
+	return nil
+}
+
+func envNodes(name string, field *[]discovery.FileNode) error {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+	nodes, err := ParseAddrs(strings.Split(v, ","))
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	*field = nodes
+	return nil
+}
+
+// ApplyEnv overrides fields from LM_* variables, LM_NODES is a comma separated host:port list
+func (c *Config) ApplyEnv() error {
+	return errors.Join(
+		envString("LM_MODE", &c.Mode),
+		envInt("LM_PORT", &c.Port),
+		envInt("LM_GRPC_PORT", &c.GRPCPort),
+		envString("LM_LOG_LEVEL", &c.LogLevel),
+		envNodes("LM_NODES", &c.Nodes),
+		envString("LM_NODES_FILE", &c.NodesFile),
+		envInt("LM_NODES_RELOAD_MS", &c.NodesReloadMs),
+		envString("LM_QUEUE", &c.Queue.Algorithm),
+		envString("LM_SELECTOR", &c.Selector),
+		envString("LM_STRATEGY", &c.Strategy),
+		envInt("LM_BATCH_SIZE", &c.Batch.Size),
+		envInt("LM_BATCH_TIMEOUT_MS", &c.Batch.TimeoutMs),
+		envInt("LM_WORKERS", &c.Workers),
+		envInt("LM_BREAKER_FAILURES", &c.Breaker.Failures),
+		envInt("LM_BREAKER_TIMEOUT_MS", &c.Breaker.TimeoutMs),
+		envInt("LM_BREAKER_PROBES", &c.Breaker.Probes),
+		envInt("LM_REQUEST_TIMEOUT_MS", &c.Timeouts.RequestMs),
+		envInt("LM_DRAIN_TIMEOUT_MS", &c.Timeouts.DrainMs),
+		envInt("LM_SHUTDOWN_TIMEOUT_MS", &c.Timeouts.ShutdownMs),
+		envInt("LM_RETRY_ATTEMPTS", &c.Retry.Attempts),
+		envInt("LM_RETRY_BACKOFF_MS", &c.Retry.BackoffMs),
+		envInt("LM_SLOW_START_MS", &c.SlowStartMs),
+		envString("LM_TRACE_FILE", &c.TraceFile),
+		envInt("LM_PROXY_HTTP_PORT_OFFSET", &c.Proxy.HTTPPortOffset),
+		envInt("LM_PROXY_MAX_BODY_BYTES", &c.Proxy.MaxBodyBytes),
+		envString("LM_BACKEND_CA", &c.BackendTLS.CA),
+		envString("LM_BACKEND_CERT", &c.BackendTLS.Cert),
+		envString("LM_BACKEND_KEY", &c.BackendTLS.Key),
+		envString("LM_BACKEND_SERVER_NAME", &c.BackendTLS.ServerName),
+		envString("LM_TLS_CERT", &c.TLS.Cert),
+		envString("LM_TLS_KEY", &c.TLS.Key),
+		envString("LM_API_KEYS", &c.Auth.KeysFile),
+		envFloat("LM_RATE_LIMIT", &c.RateLimit.Rate),
+		envInt("LM_RATE_BURST", &c.RateLimit.Burst),
+		envInt("LM_ADMISSION_TARGET_MS", &c.Admission.TargetMs),
+		envInt("LM_ADMISSION_INTERVAL_MS", &c.Admission.IntervalMs),
+		envInt("LM_ADMISSION_MAX_PRIORITY", &c.Admission.MaxPriority),
+		envString("LM_CONCURRENCY", &c.Concurrency.Algorithm),
+		envInt("LM_CONCURRENCY_MAX", &c.Concurrency.Max),
+		envFloat("LM_HEDGE_PERCENTILE", &c.Hedge.Percentile),
+		envInt("LM_CACHE_SIZE", &c.Cache.Size),
+		envInt("LM_CACHE_TTL_MS", &c.Cache.TTLMs),
+		envBool("LM_COALESCE_WRITES", &c.Batch.CoalesceWrites),
+		envBool("LM_BATCH_ADAPTIVE", &c.Batch.Adaptive),
+		envInt("LM_BATCH_MAX_BYTES", &c.Batch.MaxBytes),
+		envInt("LM_BATCH_MAX_AGE_MS", &c.Batch.MaxAgeMs),
+	)
+}
+
+func atLeast(field string, value, min int) error {
+	if value < min {
+		return fmt.Errorf("%s: must be at least %d, got %d", field, min, value)
+	}
+	return nil
+}
+
+// Bounds only matter to adaptive batches
+func validateBatch(resource string, s BatchSettings) error {
+	field := "batch"
+	if resource != "" {
+		field = "batch.resources." + resource
+	}
+	switch {
+	case s.MaxBytes < 0:
+		return fmt.Errorf("%s.max_bytes: must be at least 0, got %d", field, s.MaxBytes)
+	case s.MaxAgeMs < 0:
+		return fmt.Errorf("%s.max_age_ms: must be at least 0, got %d", field, s.MaxAgeMs)
+	case !s.Adaptive:
+		return nil
+	case s.MinSize < 1:
+		return fmt.Errorf("%s.min_size: must be at least 1, got %d", field, s.MinSize)
+	case s.Size < s.MinSize || s.Size > s.MaxSize:
+		return fmt.Errorf("%s.size: must be between min_size %d and max_size %d, got %d",
+			field, s.MinSize, s.MaxSize, s.Size)
+	case s.MinTimeoutMs < 1:
+		return fmt.Errorf("%s.min_timeout_ms: must be at least 1, got %d", field, s.MinTimeoutMs)
+	case s.TimeoutMs < s.MinTimeoutMs || s.TimeoutMs > s.MaxTimeoutMs:
+		return fmt.Errorf("%s.timeout_ms: must be between min_timeout_ms %d and max_timeout_ms %d, got %d",
+			field, s.MinTimeoutMs, s.MaxTimeoutMs, s.TimeoutMs)
+	}
+	return nil
+}
+
+// Validate reports every invalid field, named by its YAML path
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if c.Mode != ModeBatch && c.Mode != ModeProxy {
+		add(fmt.Errorf("mode: invalid mode %s. Must be: batch, proxy", c.Mode))
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		add(fmt.Errorf("port: out of range %d", c.Port))
+	}
+	switch {
+	case c.GRPCPort < 0 || c.GRPCPort > 65535:
+		add(fmt.Errorf("grpc_port: out of range %d", c.GRPCPort))
+	case c.GRPCPort == c.Port:
+		add(fmt.Errorf("grpc_port: same as port %d", c.Port))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		add(fmt.Errorf("log_level: invalid level %s. Must be: debug, info, warn, error", c.LogLevel))
+	}
+
+	switch {
+	case len(c.Nodes) == 0 && c.NodesFile == "":
+		add(errors.New("nodes: one of nodes or nodes_file is required"))
+	case len(c.Nodes) > 0 && c.NodesFile != "":
+		add(errors.New("nodes_file: cannot be combined with nodes"))
+	}
+	add(discovery.ValidateNodes(c.Nodes))
+	add(atLeast("nodes_reload_ms", c.NodesReloadMs, 1))
+
+	if _, err := algorithms.NewQueue(c.Queue.Algorithm); err != nil {
+		add(fmt.Errorf("queue.algorithm: %w", err))
+	}
+	if _, err := selector.NewSelector(c.Selector); err != nil {
+		add(fmt.Errorf("selector: %w", err))
+	}
+	if _, err := strategy.NewStrategy(c.Strategy); err != nil {
+		add(fmt.Errorf("strategy: %w", err))
+	}
+
+	add(atLeast("batch.size", c.Batch.Size, 1))
+	add(atLeast("batch.timeout_ms", c.Batch.TimeoutMs, 1))
+	for name := range c.Batch.Resources {
+		switch name {
+		case "user", "product", "order":
+		default:
+			add(fmt.Errorf("batch.resources.%s: invalid resource. Must be: user, product, order", name))
+		}
+	}
+	add(validateBatch("", c.Batch.BatchSettings))
+	for _, name := range []string{"user", "product", "order"} {
+		if _, ok := c.Batch.Resources[name]; ok {
+			add(validateBatch(name, c.Batch.For(name)))
+		}
+	}
+	add(atLeast("workers", c.Workers, 1))
+	add(atLeast("breaker.failures", c.Breaker.Failures, 1))
+	add(atLeast("breaker.timeout_ms", c.Breaker.TimeoutMs, 1))
+	add(atLeast("breaker.probes", c.Breaker.Probes, 1))
+	add(atLeast("timeouts.request_ms", c.Timeouts.RequestMs, 1))
+	add(atLeast("timeouts.drain_ms", c.Timeouts.DrainMs, 0))
+	add(atLeast("timeouts.shutdown_ms", c.Timeouts.ShutdownMs, 0))
+	add(atLeast("retry.attempts", c.Retry.Attempts, 1))
+	add(atLeast("retry.backoff_ms", c.Retry.BackoffMs, 0))
+	add(atLeast("slow_start_ms", c.SlowStartMs, 0))
+	add(atLeast("proxy.max_body_bytes", c.Proxy.MaxBodyBytes, 1))
+	if (c.BackendTLS.Cert == "") != (c.BackendTLS.Key == "") {
+		add(errors.New("backend_tls.key: cert and key must be given together"))
+	}
+	add(atLeast("backend_tls.reload_ms", c.BackendTLS.ReloadMs, 1))
+	if (c.TLS.Cert == "") != (c.TLS.Key == "") {
+		add(errors.New("tls.key: cert and key must be given together"))
+	}
+	add(atLeast("tls.reload_ms", c.TLS.ReloadMs, 1))
+	add(atLeast("auth.reload_ms", c.Auth.ReloadMs, 1))
+	if c.RateLimit.Rate < 0 {
+		add(fmt.Errorf("rate_limit.rate: must not be negative, got %v", c.RateLimit.Rate))
+	}
+	add(atLeast("rate_limit.burst", c.RateLimit.Burst, 0))
+	if err := ratelimit.ValidateRules(c.RateLimit.Routes); err != nil {
+		add(fmt.Errorf("rate_limit.%w", err))
+	}
+	add(atLeast("admission.target_ms", c.Admission.TargetMs, 0))
+	add(atLeast("admission.interval_ms", c.Admission.IntervalMs, 1))
+	if _, err := concurrency.ParseAlgorithm(c.Concurrency.Algorithm); err != nil {
+		add(fmt.Errorf("concurrency.algorithm: %w", err))
+	}
+	add(atLeast("concurrency.min", c.Concurrency.Min, 1))
+	add(atLeast("concurrency.max", c.Concurrency.Max, c.Concurrency.Min))
+	if c.Concurrency.Initial < c.Concurrency.Min || c.Concurrency.Initial > c.Concurrency.Max {
+		add(fmt.Errorf("concurrency.initial: must be between min %d and max %d, got %d",
+			c.Concurrency.Min, c.Concurrency.Max, c.Concurrency.Initial))
+	}
+	if c.Concurrency.Backoff <= 0 || c.Concurrency.Backoff >= 1 {
+		add(fmt.Errorf("concurrency.backoff: must be between 0 and 1, got %v", c.Concurrency.Backoff))
+	}
+	if c.Hedge.Percentile < 0 || c.Hedge.Percentile >= 100 {
+		add(fmt.Errorf("hedge.percentile: must be between 0 and 100, got %v", c.Hedge.Percentile))
+	}
+	add(atLeast("hedge.min_delay_ms", c.Hedge.MinDelayMs, 0))
+	add(atLeast("cache.size", c.Cache.Size, 0))
+	add(atLeast("cache.ttl_ms", c.Cache.TTLMs, 1))
+
+	return errors.Join(errs...)
+}
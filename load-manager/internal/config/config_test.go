@@ -0,0 +1,115 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse_OverDefaults(t *testing.T) {
+	conf, err := Parse([]byte(`
+port: 8100
+nodes:
+  - host: localhost
+    port: 50001
+queue:
+  algorithm: SJF
+batch:
+  size: 50
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if err := conf.Validate(); err != nil {
+		t.Fatalf("Expected valid config, got %v", err)
+	}
+
+	if conf.Port != 8100 || conf.Queue.Algorithm != "SJF" || conf.Batch.Size != 50 {
+		t.Errorf("File values not applied: %+v", conf)
+	}
+	if conf.Batch.TimeoutMs != Default().Batch.TimeoutMs || conf.Workers != Default().Workers {
+		t.Errorf("Defaults lost for fields missing from the file: %+v", conf)
+	}
+}
+
+func TestParse_BatchResources(t *testing.T) {
+	conf, err := Parse([]byte(`
+nodes:
+  - host: localhost
+    port: 50001
+batch:
+  size: 50
+  adaptive: true
+  resources:
+    order:
+      size: 20
+      max_timeout_ms: 5
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if err := conf.Validate(); err != nil {
+		t.Fatalf("Expected valid config, got %v", err)
+	}
+
+	order, user := conf.Batch.For("order"), conf.Batch.For("user")
+	if order.Size != 20 || order.MaxTimeoutMs != 5 || !order.Adaptive {
+		t.Errorf("Order overrides not applied: %+v", order)
+	}
+	if order.TimeoutMs != Default().Batch.TimeoutMs || user.Size != 50 {
+		t.Errorf("Shared settings not inherited: order %+v, user %+v", order, user)
+	}
+
+	conf.Batch.Resources["order"] = BatchSettings{Size: 5000}
+	if err := conf.Validate(); err == nil || !strings.Contains(err.Error(), "batch.resources.order.size:") {
+		t.Errorf("Expected error naming batch.resources.order.size, got %v", err)
+	}
+	conf.Batch.Resources = map[string]BatchSettings{"cart": {}}
+	if err := conf.Validate(); err == nil || !strings.Contains(err.Error(), "batch.resources.cart") {
+		t.Errorf("Expected error naming batch.resources.cart, got %v", err)
+	}
+}
+
+func TestParse_UnknownField(t *testing.T) {
+	if _, err := Parse([]byte("queue:\n  algo: FCFS\n")); err == nil {
+		t.Errorf("Expected error for unknown field")
+	}
+}
+
+func TestValidate_NamesFields(t *testing.T) {
+	conf := Default()
+	conf.Nodes, _ = ParseAddrs([]string{"localhost:50001", "localhost:0"})
+	conf.Selector = "NOPE"
+	conf.Batch.Size = 0
+	conf.Retry.Attempts = 0
+	conf.Mode = "tcp"
+	conf.Batch.MaxAgeMs = -1
+
+	err := conf.Validate()
+	if err == nil {
+		t.Fatalf("Expected validation errors")
+	}
+	for _, field := range []string{"mode", "nodes[1].port", "selector", "batch.size", "batch.max_age_ms", "retry.attempts"} {
+		if !strings.Contains(err.Error(), field+":") {
+			t.Errorf("Expected error naming %s, got %v", field, err)
+		}
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	t.Setenv("LM_WORKERS", "9")
+	t.Setenv("LM_NODES", "a:1,b:2")
+	t.Setenv("LM_QUEUE", "LJF")
+
+	conf := Default()
+	if err := conf.ApplyEnv(); err != nil {
+		t.Fatalf("ApplyEnv failed: %v", err)
+	}
+	if conf.Workers != 9 || conf.Queue.Algorithm != "LJF" || len(conf.Nodes) != 2 {
+		t.Errorf("Env not applied: %+v", conf)
+	}
+
+	t.Setenv("LM_BATCH_SIZE", "many")
+	if err := conf.ApplyEnv(); err == nil || !strings.Contains(err.Error(), "LM_BATCH_SIZE") {
+		t.Errorf("Expected error naming LM_BATCH_SIZE, got %v", err)
+	}
+}
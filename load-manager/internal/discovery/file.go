@@ -0,0 +1,233 @@
+package discovery
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/sudo-JP/Load-Manager/common/filewatch"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/registry"
+)
+
+/*
+Nodes file format
+
+	nodes:
+	  - host: localhost
+	    port: 50001
+	    weight: 2
+	    labels:
+	      zone: a
+*/
+type FileNode struct {
+	Host   string            `yaml:"host"`
+	Port   int               `yaml:"port"`
+	Weight *int              `yaml:"weight"`
+	Labels map[string]string `yaml:"labels"`
+}
+
+type NodesFile struct {
+	Nodes []FileNode `yaml:"nodes"`
+}
+
+func (f FileNode) addr() string {
+	return fmt.Sprintf("%s:%d", f.Host, f.Port)
+}
+
+// Weight defaults to 1 when omitted
+func (f FileNode) weight() int {
+	if f.Weight == nil {
+		return 1
+	}
+	return *f.Weight
+}
+
+func ParseNodesFile(data []byte) (*NodesFile, error) {
+	var file NodesFile
+	if err := yaml.UnmarshalWithOptions(data, &file, yaml.Strict()); err != nil {
+		return nil, err
+	}
+	if err := ValidateNodes(file.Nodes); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// ValidateNodes names the first offending field, e.g. nodes[2].port
+func ValidateNodes(nodes []FileNode) error {
+	seen := make(map[string]bool)
+	for i, node := range nodes {
+		if node.Host == "" {
+			return fmt.Errorf("nodes[%d].host: required", i)
+		}
+		if node.Port < 1 || node.Port > 65535 {
+			return fmt.Errorf("nodes[%d].port: out of range %d", i, node.Port)
+		}
+		if node.weight() < 0 {
+			return fmt.Errorf("nodes[%d].weight: must not be negative", i)
+		}
+		if seen[node.addr()] {
+			return fmt.Errorf("nodes[%d]: duplicate node %s", i, node.addr())
+		}
+		seen[node.addr()] = true
+	}
+	return nil
+}
+
+// AddNodes registers a static node list with its weights and labels
+func AddNodes(reg *registry.Registry, nodes []FileNode) {
+	for _, fileNode := range nodes {
+		node, added := reg.AddIfAbsent(fileNode.Host, fileNode.Port)
+		if added && (fileNode.weight() != 1 || fileNode.Labels != nil) {
+			reg.Update(node.ID, fileNode.weight(), fileNode.Labels)
+		}
+	}
+}
+
+func LoadNodesFile(path string) (*NodesFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseNodesFile(data)
+}
+
+/*
+FileWatcher polls the nodes file and reconciles the registry with it:
+new nodes are added, removed nodes are drained, weights and labels are updated.
+Only nodes the watcher added are reconciled, nodes from -a or the admin API
+are left alone and a file entry at one of their addresses is skipped.
+
+A node is drained once however many reloads happen meanwhile. A node put
+back in the file while its address is still draining is added after the
+drain, so the pending removal never catches the new one
+*/
+type FileWatcher struct {
+	path     string
+	registry *registry.Registry
+	remove   func(id int) error // drains then removes a node
+	watcher  *filewatch.Watcher
+
+	mutex    sync.Mutex
+	added    map[string]int // address -> id of the node the watcher added there
+	removing map[string]int // address -> id of the node draining there
+}
+
+// Reload reads the file and reconciles if the content changed
+func (fw *FileWatcher) Reload() error {
+	return fw.watcher.Reload()
+}
+
+func (fw *FileWatcher) load(data [][]byte) error {
+	file, err := ParseNodesFile(data[0])
+	if err != nil {
+		return err
+	}
+	fw.reconcile(file)
+	return nil
+}
+
+func (fw *FileWatcher) reconcile(file *NodesFile) {
+	fw.mutex.Lock()
+	defer fw.mutex.Unlock()
+
+	wanted := make(map[string]FileNode)
+	for _, node := range file.Nodes {
+		wanted[node.addr()] = node
+	}
+
+	owned := make(map[string]int)
+	for _, node := range fw.registry.All() {
+		addr := node.Addr()
+		if id, ok := fw.removing[addr]; ok && id == node.ID {
+			continue // already draining
+		}
+		fileNode, ok := wanted[addr]
+
+		// Added some other way
+		if id, mine := fw.added[addr]; !mine || id != node.ID {
+			if ok {
+				slog.Warn("Node in nodes file already added elsewhere, skipping", "node", addr, "file", fw.path)
+				delete(wanted, addr)
+			}
+			continue
+		}
+
+		// Removed from file
+		if !ok {
+			slog.Info("Node removed from nodes file, draining", "node", addr, "file", fw.path)
+			fw.removeLocked(addr, node.ID)
+			continue
+		}
+
+		// Still there, update in place
+		owned[addr] = node.ID
+		delete(wanted, addr)
+		if int(atomic.LoadInt32(&node.Weight)) != fileNode.weight() ||
+			!maps.Equal(fw.registry.Labels(node), fileNode.Labels) {
+			slog.Info("Updating node", "node", addr, "weight", fileNode.weight(), "labels", fileNode.Labels)
+			fw.registry.Update(node.ID, fileNode.weight(), fileNode.Labels)
+		}
+	}
+	// Forget nodes removed through the admin API
+	fw.added = owned
+
+	// New in file
+	for _, fileNode := range file.Nodes {
+		addr := fileNode.addr()
+		if _, ok := wanted[addr]; !ok {
+			continue
+		}
+		if _, draining := fw.removing[addr]; draining {
+			slog.Info("Node back in nodes file while draining, adding it after", "node", addr, "file", fw.path)
+			fw.watcher.Touch() // reconcile again on the next poll
+			continue
+		}
+		node, added := fw.registry.AddIfAbsent(fileNode.Host, fileNode.Port)
+		if added {
+			slog.Info("Adding node from nodes file", "node", addr, "file", fw.path)
+			fw.registry.Update(node.ID, fileNode.weight(), fileNode.Labels)
+			fw.added[addr] = node.ID
+		}
+	}
+}
+
+// Must hold lock
+func (fw *FileWatcher) removeLocked(addr string, id int) {
+	delete(fw.added, addr)
+	fw.removing[addr] = id
+	go func() {
+		if err := fw.remove(id); err != nil {
+			slog.Error("Failed to remove node", "node", addr, "error", err)
+		}
+		fw.mutex.Lock()
+		delete(fw.removing, addr)
+		fw.mutex.Unlock()
+	}()
+}
+
+func (fw *FileWatcher) Start() {
+	fw.watcher.Start()
+}
+
+func (fw *FileWatcher) Stop() {
+	fw.watcher.Stop()
+}
+
+func NewFileWatcher(path string, reg *registry.Registry,
+	remove func(id int) error, interval time.Duration) *FileWatcher {
+	fw := &FileWatcher{
+		path:     path,
+		registry: reg,
+		remove:   remove,
+		added:    make(map[string]int),
+		removing: make(map[string]int),
+	}
+	fw.watcher = filewatch.New("nodes file", []string{path}, interval, fw.load)
+	return fw
+}
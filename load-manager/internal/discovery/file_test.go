@@ -0,0 +1,191 @@
+package discovery
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/registry"
+)
+
+func TestParseNodesFile(t *testing.T) {
+	file, err := ParseNodesFile([]byte(`
+nodes:
+  - host: localhost
+    port: 50001
+    weight: 2
+    labels:
+      zone: a
+  - host: localhost
+    port: 50002
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(file.Nodes) != 2 || file.Nodes[0].weight() != 2 || file.Nodes[0].Labels["zone"] != "a" {
+		t.Errorf("Unexpected nodes: %+v", file.Nodes)
+	}
+	if file.Nodes[1].weight() != 1 {
+		t.Errorf("Expected weight to default to 1, got %d", file.Nodes[1].weight())
+	}
+
+	if _, err := ParseNodesFile([]byte("nodes:\n  - host: localhost\n    prt: 1\n")); err == nil {
+		t.Errorf("Expected error for unknown field")
+	}
+}
+
+func TestValidateNodes(t *testing.T) {
+	negative := -1
+	tests := []struct {
+		nodes []FileNode
+		field string
+	}{
+		{[]FileNode{{Host: "a", Port: 1}, {Port: 2}}, "nodes[1].host"},
+		{[]FileNode{{Host: "a", Port: 70000}}, "nodes[0].port"},
+		{[]FileNode{{Host: "a", Port: 1, Weight: &negative}}, "nodes[0].weight"},
+		{[]FileNode{{Host: "a", Port: 1}, {Host: "a", Port: 1}}, "nodes[1]: duplicate"},
+	}
+	for _, tt := range tests {
+		err := ValidateNodes(tt.nodes)
+		if err == nil || !strings.HasPrefix(err.Error(), tt.field) {
+			t.Errorf("Expected error naming %s, got %v", tt.field, err)
+		}
+	}
+	if err := ValidateNodes([]FileNode{{Host: "a", Port: 1}, {Host: "a", Port: 2}}); err != nil {
+		t.Errorf("Expected valid nodes, got %v", err)
+	}
+}
+
+// removals stands in for Worker.RemoveNode, drains block until released
+type removals struct {
+	reg     *registry.Registry
+	calls   atomic.Int32
+	release chan struct{}
+	done    sync.WaitGroup
+}
+
+func (r *removals) remove(id int) error {
+	defer r.done.Done()
+	r.calls.Add(1)
+	r.reg.Remove(id)
+	<-r.release
+	return nil
+}
+
+func newWatcher(t *testing.T) (*FileWatcher, *removals) {
+	reg := registry.NewRegistry()
+	r := &removals{reg: reg, release: make(chan struct{})}
+	return NewFileWatcher("nodes.yaml", reg, r.remove, time.Hour), r
+}
+
+func parse(t *testing.T, data string) *NodesFile {
+	t.Helper()
+	file, err := ParseNodesFile([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestReconcile(t *testing.T) {
+	fw, r := newWatcher(t)
+	fw.reconcile(parse(t, `
+nodes:
+  - {host: localhost, port: 50001}
+  - {host: localhost, port: 50002, weight: 3}
+`))
+	if n := len(fw.registry.All()); n != 2 {
+		t.Fatalf("Expected 2 nodes added, got %d", n)
+	}
+	if node := fw.registry.Find("localhost", 50002); atomic.LoadInt32(&node.Weight) != 3 {
+		t.Errorf("Expected weight 3, got %d", node.Weight)
+	}
+
+	// 50001 updated, 50002 removed
+	r.done.Add(1)
+	fw.reconcile(parse(t, `
+nodes:
+  - {host: localhost, port: 50001, labels: {zone: b}}
+`))
+	close(r.release)
+	r.done.Wait()
+
+	nodes := fw.registry.All()
+	if len(nodes) != 1 || nodes[0].Port != 50001 {
+		t.Fatalf("Expected only 50001 left, got %d nodes", len(nodes))
+	}
+	if zone := fw.registry.Labels(nodes[0])["zone"]; zone != "b" {
+		t.Errorf("Expected labels updated to zone b, got %q", zone)
+	}
+}
+
+func TestReconcile_RemovesOnce(t *testing.T) {
+	fw, r := newWatcher(t)
+	fw.reconcile(parse(t, "nodes:\n  - {host: localhost, port: 50001}\n"))
+	old := fw.registry.Find("localhost", 50001)
+
+	r.done.Add(1)
+	empty := parse(t, "nodes: []\n")
+	fw.reconcile(empty)
+	fw.reconcile(empty)
+
+	// Back in the file while the old node drains, it waits for the drain
+	back := parse(t, "nodes:\n  - {host: localhost, port: 50001}\n")
+	fw.reconcile(back)
+	if node := fw.registry.Find("localhost", 50001); node != nil && node != old {
+		t.Errorf("Expected the node added only after the old one drained")
+	}
+
+	close(r.release)
+	r.done.Wait()
+	waitFor(t, func() bool {
+		fw.mutex.Lock()
+		defer fw.mutex.Unlock()
+		return len(fw.removing) == 0
+	})
+	fw.reconcile(back)
+
+	if n := r.calls.Load(); n != 1 {
+		t.Errorf("Expected one removal, got %d", n)
+	}
+	node := fw.registry.Find("localhost", 50001)
+	if node == nil || node == old {
+		t.Errorf("Expected a new node at the drained address, got %v", node)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestReconcile_LeavesOtherNodes(t *testing.T) {
+	fw, _ := newWatcher(t)
+	fw.reconcile(parse(t, "nodes:\n  - {host: localhost, port: 50001}\n"))
+
+	// Added through -a or the admin API
+	fw.registry.Add("localhost", 50002)
+	fw.registry.Add("localhost", 50003)
+
+	fw.reconcile(parse(t, `
+nodes:
+  - {host: localhost, port: 50001, weight: 2}
+  - {host: localhost, port: 50003, weight: 5}
+`))
+	if n := len(fw.registry.All()); n != 3 {
+		t.Fatalf("Expected nodes added elsewhere to stay, got %d nodes", n)
+	}
+	if node := fw.registry.Find("localhost", 50001); atomic.LoadInt32(&node.Weight) != 2 {
+		t.Errorf("Expected the file's own node updated to weight 2, got %d", node.Weight)
+	}
+	if node := fw.registry.Find("localhost", 50003); atomic.LoadInt32(&node.Weight) != 1 {
+		t.Errorf("Expected a node added elsewhere left at weight 1, got %d", node.Weight)
+	}
+}
@@ -1,13 +1,15 @@
 package grpc
 
 import (
-	"log"
+	"log/slog"
 
+	"github.com/sudo-JP/Load-Manager/common/tracing"
 	"github.com/sudo-JP/Load-Manager/load-manager/api/proto/order"
 	"github.com/sudo-JP/Load-Manager/load-manager/api/proto/product"
 	"github.com/sudo-JP/Load-Manager/load-manager/api/proto/user"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
@@ -18,9 +20,14 @@ type BackendClient struct {
 	Orders 		order.OrderServiceClient
 }
 
-// Constructor 
-func NewBackendClient(address string) (*BackendClient, error) {
-	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+// Constructor, nil creds connects without TLS
+func NewBackendClient(address string, creds credentials.TransportCredentials) (*BackendClient, error) {
+	if creds == nil {
+		creds = insecure.NewCredentials()
+	}
+	conn, err := grpc.NewClient(address, 
+		grpc.WithTransportCredentials(creds),
+		grpc.WithUnaryInterceptor(tracing.UnaryClientInterceptor()))
 	if err != nil {
 		return nil, err 
 	}
@@ -38,7 +45,7 @@ func (bc *BackendClient) Close() {
 	err := bc.conn.Close()
 
 	if err != nil {
-		log.Println(err)
+		slog.Error("Failed to close backend connection", "error", err)
 	}
 }
 
@@ -0,0 +1,403 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+A small Prometheus text format registry. Packages declare their metrics
+against Default and /metrics writes everything out on scrape.
+*/
+
+var DefBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type collector interface {
+	write(w io.Writer)
+}
+
+type desc struct {
+	name   string
+	help   string
+	kind   string // counter, gauge, histogram
+	labels []string
+}
+
+func (d *desc) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, d.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.kind)
+}
+
+func (d *desc) check(values []string) {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metric %s: expected %d label values, got %d",
+			d.name, len(d.labels), len(values)))
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(names, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+
+	parts := make([]string, 0, len(names)+len(extra)/2)
+	for i, name := range names {
+		parts = append(parts, name+`="`+labelEscaper.Replace(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		parts = append(parts, extra[i]+`="`+labelEscaper.Replace(extra[i+1])+`"`)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Label values joined, used as the child key
+func key(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+/*
+Counter
+*/
+
+type Counter struct {
+	values []string
+	bits   atomic.Uint64
+}
+
+func (c *Counter) Add(v float64) {
+	for {
+		old := c.bits.Load()
+		if c.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+func (c *Counter) Value() float64 {
+	return math.Float64frombits(c.bits.Load())
+}
+
+type CounterVec struct {
+	desc
+	mutex    sync.RWMutex
+	children map[string]*Counter
+}
+
+func (cv *CounterVec) With(values ...string) *Counter {
+	cv.check(values)
+	k := key(values)
+
+	cv.mutex.RLock()
+	c, ok := cv.children[k]
+	cv.mutex.RUnlock()
+	if ok {
+		return c
+	}
+
+	cv.mutex.Lock()
+	defer cv.mutex.Unlock()
+	if c, ok := cv.children[k]; ok {
+		return c
+	}
+	c = &Counter{values: slices.Clone(values)}
+	cv.children[k] = c
+	return c
+}
+
+func (cv *CounterVec) write(w io.Writer) {
+	cv.header(w)
+	cv.mutex.RLock()
+	defer cv.mutex.RUnlock()
+	for _, k := range sortedKeys(cv.children) {
+		c := cv.children[k]
+		fmt.Fprintf(w, "%s%s %s\n", cv.name, formatLabels(cv.labels, c.values), formatValue(c.Value()))
+	}
+}
+
+/*
+Gauge
+*/
+
+type Gauge struct {
+	Counter
+}
+
+func (g *Gauge) Set(v float64) {
+	g.bits.Store(math.Float64bits(v))
+}
+
+func (g *Gauge) Dec() {
+	g.Add(-1)
+}
+
+type GaugeVec struct {
+	desc
+	mutex    sync.RWMutex
+	children map[string]*Gauge
+}
+
+func (gv *GaugeVec) With(values ...string) *Gauge {
+	gv.check(values)
+	k := key(values)
+
+	gv.mutex.RLock()
+	g, ok := gv.children[k]
+	gv.mutex.RUnlock()
+	if ok {
+		return g
+	}
+
+	gv.mutex.Lock()
+	defer gv.mutex.Unlock()
+	if g, ok := gv.children[k]; ok {
+		return g
+	}
+	g = &Gauge{Counter{values: slices.Clone(values)}}
+	gv.children[k] = g
+	return g
+}
+
+// Delete drops a child, for label values that are gone for good like removed nodes
+func (gv *GaugeVec) Delete(values ...string) {
+	gv.mutex.Lock()
+	defer gv.mutex.Unlock()
+	delete(gv.children, key(values))
+}
+
+func (gv *GaugeVec) write(w io.Writer) {
+	gv.header(w)
+	gv.mutex.RLock()
+	defer gv.mutex.RUnlock()
+	for _, k := range sortedKeys(gv.children) {
+		g := gv.children[k]
+		fmt.Fprintf(w, "%s%s %s\n", gv.name, formatLabels(gv.labels, g.values), formatValue(g.Value()))
+	}
+}
+
+/*
+GaugeFunc is computed on every scrape, for values owned by someone else
+like queue length or node state
+*/
+
+type Sample struct {
+	Values []string
+	Value  float64
+}
+
+type GaugeFunc struct {
+	desc
+	fn func() []Sample
+}
+
+func (gf *GaugeFunc) write(w io.Writer) {
+	gf.header(w)
+	for _, s := range gf.fn() {
+		gf.check(s.Values)
+		fmt.Fprintf(w, "%s%s %s\n", gf.name, formatLabels(gf.labels, s.Values), formatValue(s.Value))
+	}
+}
+
+/*
+Histogram
+*/
+
+type Histogram struct {
+	values  []string
+	buckets []float64
+	mutex   sync.Mutex
+	counts  []uint64 // per bucket, not cumulative
+	sum     float64
+	count   uint64
+}
+
+func (h *Histogram) Observe(v float64) {
+	idx := sort.SearchFloat64s(h.buckets, v)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if idx < len(h.counts) {
+		h.counts[idx]++
+	}
+	h.sum += v
+	h.count++
+}
+
+type HistogramVec struct {
+	desc
+	buckets  []float64
+	mutex    sync.RWMutex
+	children map[string]*Histogram
+}
+
+func (hv *HistogramVec) With(values ...string) *Histogram {
+	hv.check(values)
+	k := key(values)
+
+	hv.mutex.RLock()
+	h, ok := hv.children[k]
+	hv.mutex.RUnlock()
+	if ok {
+		return h
+	}
+
+	hv.mutex.Lock()
+	defer hv.mutex.Unlock()
+	if h, ok := hv.children[k]; ok {
+		return h
+	}
+	h = &Histogram{
+		values:  slices.Clone(values),
+		buckets: hv.buckets,
+		counts:  make([]uint64, len(hv.buckets)),
+	}
+	hv.children[k] = h
+	return h
+}
+
+func (hv *HistogramVec) write(w io.Writer) {
+	hv.header(w)
+	hv.mutex.RLock()
+	defer hv.mutex.RUnlock()
+	for _, k := range sortedKeys(hv.children) {
+		h := hv.children[k]
+
+		h.mutex.Lock()
+		cumulative := uint64(0)
+		for i, bound := range h.buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", hv.name,
+				formatLabels(hv.labels, h.values, "le", formatValue(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", hv.name,
+			formatLabels(hv.labels, h.values, "le", "+Inf"), h.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", hv.name, formatLabels(hv.labels, h.values), formatValue(h.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", hv.name, formatLabels(hv.labels, h.values), h.count)
+		h.mutex.Unlock()
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+/*
+Registry
+*/
+
+type Registry struct {
+	mutex      sync.RWMutex
+	collectors map[string]collector
+}
+
+// Registering a name twice replaces the old metric
+func (r *Registry) register(name string, c collector) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.collectors[name] = c
+}
+
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	cv := &CounterVec{
+		desc:     desc{name: name, help: help, kind: "counter", labels: labels},
+		children: make(map[string]*Counter),
+	}
+	r.register(name, cv)
+	return cv
+}
+
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	gv := &GaugeVec{
+		desc:     desc{name: name, help: help, kind: "gauge", labels: labels},
+		children: make(map[string]*Gauge),
+	}
+	r.register(name, gv)
+	return gv
+}
+
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	buckets = slices.Clone(buckets)
+	sort.Float64s(buckets)
+	hv := &HistogramVec{
+		desc:     desc{name: name, help: help, kind: "histogram", labels: labels},
+		buckets:  buckets,
+		children: make(map[string]*Histogram),
+	}
+	r.register(name, hv)
+	return hv
+}
+
+func (r *Registry) NewGaugeFunc(name, help string, labels []string, fn func() []Sample) {
+	r.register(name, &GaugeFunc{
+		desc: desc{name: name, help: help, kind: "gauge", labels: labels},
+		fn:   fn,
+	})
+}
+
+func (r *Registry) WriteText(w io.Writer) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for _, name := range sortedKeys(r.collectors) {
+		r.collectors[name].write(w)
+	}
+}
+
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		collectors: make(map[string]collector),
+	}
+}
+
+// Default is the registry served on /metrics
+var Default = NewRegistry()
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return Default.NewCounterVec(name, help, labels...)
+}
+
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return Default.NewGaugeVec(name, help, labels...)
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return Default.NewHistogramVec(name, help, buckets, labels...)
+}
+
+func NewGaugeFunc(name, help string, labels []string, fn func() []Sample) {
+	Default.NewGaugeFunc(name, help, labels, fn)
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	r := NewRegistry()
+
+	jobs := r.NewCounterVec("jobs_total", "Jobs", "resource", "outcome")
+	jobs.With("user", "success").Inc()
+	jobs.With("user", "success").Add(2)
+	jobs.With("order", "error").Inc()
+
+	latency := r.NewHistogramVec("latency_seconds", "Latency", []float64{0.1, 1}, "node")
+	latency.With("a:1").Observe(0.05)
+	latency.With("a:1").Observe(0.5)
+	latency.With("a:1").Observe(5)
+
+	r.NewGaugeFunc("queue_length", "Queue", []string{"algorithm"}, func() []Sample {
+		return []Sample{{Values: []string{`FC"FS`}, Value: 7}}
+	})
+
+	var out strings.Builder
+	r.WriteText(&out)
+	text := out.String()
+
+	expected := []string{
+		"# TYPE jobs_total counter",
+		`jobs_total{resource="user",outcome="success"} 3`,
+		`jobs_total{resource="order",outcome="error"} 1`,
+		"# TYPE latency_seconds histogram",
+		`latency_seconds_bucket{node="a:1",le="0.1"} 1`,
+		`latency_seconds_bucket{node="a:1",le="1"} 2`,
+		`latency_seconds_bucket{node="a:1",le="+Inf"} 3`,
+		`latency_seconds_count{node="a:1"} 3`,
+		`queue_length{algorithm="FC\"FS"} 7`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("Missing line %q in\n%s", line, text)
+		}
+	}
+}
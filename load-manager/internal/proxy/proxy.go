@@ -0,0 +1,216 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/breaker"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/metrics"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/registry"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/selector"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/worker"
+)
+
+// Backends serve HTTP on 9000+id next to gRPC on 50000+id
+const DefaultPortOffset = 9000 - 50000
+
+// Request body limit when Options leaves it unset
+const DefaultMaxBodyBytes = 1 << 20
+
+// Node label that overrides the offset
+const HTTPPortLabel = "http_port"
+
+var (
+	proxyRequests = metrics.NewCounterVec("lm_proxy_requests_total",
+		"Proxied HTTP requests by node and status code", "node", "code")
+	proxyLatency = metrics.NewHistogramVec("lm_proxy_request_duration_seconds",
+		"Backend HTTP latency per node", metrics.DefBuckets, "node")
+	proxyRetries = metrics.NewCounterVec("lm_proxy_retries_total",
+		"Proxied requests repeated after the node could not be reached", "node")
+)
+
+// Headers that only apply to one connection, never forwarded
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+type Options struct {
+	Timeout    time.Duration // per attempt, response body included
+	Retry      worker.RetryPolicy
+	PortOffset int
+	// Larger request bodies are refused with 413, they are held in memory for retries
+	MaxBodyBytes int64
+}
+
+/*
+Proxy forwards each HTTP request as is to one backend's HTTP server,
+no queue or batching. Nodes are picked like the worker does, through
+the current selector over available nodes, guarded by their breakers
+*/
+type Proxy struct {
+	registry *registry.Registry
+	selector func() selector.Selector // follows selector switches
+	client   *http.Client
+	opts     Options
+}
+
+// HTTPAddr is the node's HTTP host:port
+func (p *Proxy) HTTPAddr(node *registry.BackendNode) string {
+	port := node.Port + p.opts.PortOffset
+	if v, ok := p.registry.Labels(node)[HTTPPortLabel]; ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			port = n
+		}
+	}
+	return fmt.Sprintf("%s:%d", node.Host, port)
+}
+
+// The request never reached the node, safe to send elsewhere even for writes
+func retryable(err error) bool {
+	if errors.Is(err, breaker.ErrOpen) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "{\"error\":%q}\n", err.Error())
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Buffered so a retry can send it again
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, p.opts.MaxBodyBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	for attempt := 1; ; attempt++ {
+		node := p.selector().SelectNode(p.registry.Available())
+		if node == nil {
+			writeError(w, http.StatusServiceUnavailable, worker.ErrNoNode)
+			return
+		}
+
+		err := p.forward(w, r, node, body)
+		if err == nil {
+			return
+		}
+		if attempt >= p.opts.Retry.Attempts || !retryable(err) {
+			slog.Error("Proxy request failed", "node", node.Addr(), "path", r.URL.Path, "error", err)
+			proxyRequests.With(node.Addr(), strconv.Itoa(http.StatusBadGateway)).Inc()
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		proxyRetries.With(node.Addr()).Inc()
+		timer := time.NewTimer(p.opts.Retry.Backoff * time.Duration(attempt))
+		select {
+		case <-r.Context().Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// forward returns an error only when no response was written
+func (p *Proxy) forward(w http.ResponseWriter, r *http.Request,
+	node *registry.BackendNode, body []byte) error {
+	ctx, cancel := context.WithTimeout(r.Context(), p.opts.Timeout)
+	defer cancel()
+
+	target := "http://" + p.HTTPAddr(node) + r.URL.RequestURI()
+	req, err := http.NewRequestWithContext(ctx, r.Method, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = r.Header.Clone()
+	for _, h := range hopHeaders {
+		req.Header.Del(h)
+	}
+	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		req.Header.Set("X-Forwarded-For", ip)
+	}
+
+	if err := node.Breaker.Allow(); err != nil {
+		return fmt.Errorf("node %s: %w", node.Addr(), err)
+	}
+
+	atomic.AddInt32(&node.ActiveReqCount, 1)
+	defer atomic.AddInt32(&node.ActiveReqCount, -1)
+
+	start := time.Now()
+	resp, err := p.client.Do(req)
+	if err != nil {
+		// A client that went away says nothing about the node
+		if r.Context().Err() != nil {
+			node.Breaker.Ignore()
+		} else {
+			node.Breaker.Failure()
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Like the worker, only gateway errors count against the node
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		node.Breaker.Failure()
+	default:
+		node.Breaker.Success()
+	}
+
+	for k, values := range resp.Header {
+		for _, v := range values {
+			w.Header().Add(k, v)
+		}
+	}
+	for _, h := range hopHeaders {
+		w.Header().Del(h)
+	}
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		// Headers are out, nothing left to report to the client
+		slog.Warn("Proxy response copy failed", "node", node.Addr(), "error", err)
+	}
+
+	proxyLatency.With(node.Addr()).Observe(time.Since(start).Seconds())
+	proxyRequests.With(node.Addr(), strconv.Itoa(resp.StatusCode)).Inc()
+	return nil
+}
+
+func NewProxy(reg *registry.Registry, sel func() selector.Selector, opts Options) *Proxy {
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	return &Proxy{
+		registry: reg,
+		selector: sel,
+		client:   &http.Client{},
+		opts:     opts,
+	}
+}
@@ -0,0 +1,175 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/breaker"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/registry"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/selector"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/worker"
+)
+
+func port(t *testing.T, addr string) int {
+	t.Helper()
+	_, p, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := strconv.Atoi(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// A port nothing listens on, dials to it are refused
+func closedPort(t *testing.T) int {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis.Close()
+	return port(t, lis.Addr().String())
+}
+
+func newProxy(reg *registry.Registry, opts Options) *Proxy {
+	sel := selector.NewRR()
+	if opts.Timeout == 0 {
+		opts.Timeout = time.Second
+	}
+	return NewProxy(reg, func() selector.Selector { return sel }, opts)
+}
+
+func backend(t *testing.T, handler http.HandlerFunc) int {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return port(t, srv.Listener.Addr().String())
+}
+
+func TestServeHTTP_RetriesUnreachableNode(t *testing.T) {
+	reg := registry.NewRegistry()
+	down := reg.Add("127.0.0.1", closedPort(t))
+	reg.Add("127.0.0.1", backend(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	prx := newProxy(reg, Options{Retry: worker.RetryPolicy{Attempts: 2}})
+
+	rec := httptest.NewRecorder()
+	prx.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/single/user", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Errorf("Expected the second node's answer, got %d %q", rec.Code, rec.Body.String())
+	}
+	if n := down.Breaker.Stats().Failures; n != 1 {
+		t.Errorf("Expected the refused dial to count against the node, got %d failures", n)
+	}
+
+	// Without retries the dial error is the answer
+	prx = newProxy(reg, Options{Retry: worker.RetryPolicy{Attempts: 1}})
+	rec = httptest.NewRecorder()
+	prx.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/single/user", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("Expected 502, got %d", rec.Code)
+	}
+}
+
+func TestServeHTTP_HopHeaders(t *testing.T) {
+	reg := registry.NewRegistry()
+	var got http.Header
+	reg.Add("127.0.0.1", backend(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Proxy-Authenticate", "Basic")
+		w.Header().Set("X-Backend", "1")
+	}))
+	prx := newProxy(reg, Options{})
+
+	req := httptest.NewRequest(http.MethodGet, "/single/users", nil)
+	req.Header.Set("Proxy-Authorization", "Basic secret")
+	req.Header.Set("Keep-Alive", "timeout=5")
+	req.Header.Set("X-Api-Key", "key")
+	rec := httptest.NewRecorder()
+	prx.ServeHTTP(rec, req)
+
+	for _, h := range []string{"Proxy-Authorization", "Keep-Alive"} {
+		if v := got.Get(h); v != "" {
+			t.Errorf("Expected %s dropped, backend got %q", h, v)
+		}
+	}
+	if got.Get("X-Api-Key") != "key" || got.Get("X-Forwarded-For") == "" {
+		t.Errorf("Expected end-to-end headers forwarded, backend got %v", got)
+	}
+	if rec.Header().Get("Proxy-Authenticate") != "" || rec.Header().Get("X-Backend") != "1" {
+		t.Errorf("Expected only end-to-end response headers, got %v", rec.Header())
+	}
+}
+
+func TestServeHTTP_Breaker(t *testing.T) {
+	reg := registry.NewRegistry()
+	reg.SetBreakerConfig(breaker.Config{FailureThreshold: 1, OpenTimeout: time.Hour})
+	node := reg.Add("127.0.0.1", backend(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	prx := newProxy(reg, Options{})
+
+	rec := httptest.NewRecorder()
+	prx.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/single/users", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected the backend's 503 passed through, got %d", rec.Code)
+	}
+	if node.Breaker.State() != breaker.Open {
+		t.Fatalf("Expected a 503 to open the breaker, got %v", node.Breaker.State())
+	}
+
+	// An open circuit leaves no node to pick
+	rec = httptest.NewRecorder()
+	prx.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/single/users", nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), worker.ErrNoNode.Error()) {
+		t.Errorf("Expected no node, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServeHTTP_ClientGoneIsNotAFailure(t *testing.T) {
+	reg := registry.NewRegistry()
+	reg.SetBreakerConfig(breaker.Config{FailureThreshold: 1, OpenTimeout: time.Hour})
+	started := make(chan struct{})
+	node := reg.Add("127.0.0.1", backend(t, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	}))
+	prx := newProxy(reg, Options{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	req := httptest.NewRequest(http.MethodGet, "/single/users", nil).WithContext(ctx)
+	prx.ServeHTTP(httptest.NewRecorder(), req)
+
+	if stats := node.Breaker.Stats(); stats.State != breaker.Closed || stats.Failures != 0 {
+		t.Errorf("Expected a cancelled request not to count, got %v with %d failures", stats.State, stats.Failures)
+	}
+}
+
+func TestServeHTTP_BodyLimit(t *testing.T) {
+	reg := registry.NewRegistry()
+	called := false
+	reg.Add("127.0.0.1", backend(t, func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	prx := newProxy(reg, Options{MaxBodyBytes: 4})
+
+	rec := httptest.NewRecorder()
+	prx.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/single/user", strings.NewReader(`{"name":"x"}`)))
+	if rec.Code != http.StatusRequestEntityTooLarge || called {
+		t.Errorf("Expected 413 without reaching the backend, got %d", rec.Code)
+	}
+}
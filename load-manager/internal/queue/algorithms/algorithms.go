@@ -0,0 +1,24 @@
+package algorithms
+
+import (
+	"fmt"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+)
+
+// NewQueue builds a queue from its CLI name
+func NewQueue(name string) (queue.Queue, error) {
+	switch name {
+	case "FCFS":
+		return NewFCFSQueue(), nil
+	case "SJF":
+		return NewSJF(), nil
+	case "LJF":
+		return NewLJF(), nil
+	case "RANDOM":
+		return NewRand(), nil
+	case "STACK":
+		return NewStackQueue(), nil
+	}
+	return nil, fmt.Errorf("invalid queue type %s. Must be: FCFS, SJF, LJF, RANDOM, STACK", name)
+}
@@ -19,13 +19,12 @@ type FCFS struct {
 }
 
 func (q *FCFS) resizeQueue(oldCap int, tempArr []*queue.Job) {
-	idx := 0 
-	for i := q.head; i != q.tail; i = (i + 1) % oldCap {
-		tempArr[idx] = q.jobs[i]
-		idx++
+	// Walk by size, head == tail when the ring is full
+	for idx := range q.size {
+		tempArr[idx] = q.jobs[(q.head + idx) % oldCap]
 	}
-	q.head = 0 
-	q.tail = idx
+	q.head = 0
+	q.tail = q.size % q.capacity
 	q.jobs = tempArr
 }
 
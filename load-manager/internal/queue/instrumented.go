@@ -0,0 +1,145 @@
+package queue
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/metrics"
+)
+
+var (
+	pushedJobs = metrics.NewCounterVec("lm_queue_pushed_total",
+		"Jobs pushed onto the queue", "algorithm")
+	poppedJobs = metrics.NewCounterVec("lm_queue_popped_total",
+		"Jobs popped off the queue", "algorithm")
+	swaps = metrics.NewCounterVec("lm_queue_swaps_total",
+		"Queue algorithm changes at runtime", "from", "to")
+)
+
+/*
+Instrumented reports queue length and throughput per algorithm, and keeps
+track of waiting jobs so the oldest one can be found whatever the algorithm.
+The algorithm underneath can be swapped while running, see Swap
+*/
+type Instrumented struct {
+	mutex     sync.RWMutex // guards queue and algorithm, held for writing only by Swap
+	queue     Queue
+	algorithm string
+
+	waitingMutex sync.Mutex
+	waiting      map[*Job]struct{}
+}
+
+func (q *Instrumented) Pushs(jobs []*Job) []error {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	q.waitingMutex.Lock()
+	for _, job := range jobs {
+		if job != nil {
+			q.waiting[job] = struct{}{}
+		}
+	}
+	q.waitingMutex.Unlock()
+
+	errs := q.queue.Pushs(jobs)
+	pushedJobs.With(q.algorithm).Add(float64(len(jobs)))
+	return errs
+}
+
+func (q *Instrumented) Pops() ([]*Job, []error) {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	jobs, errs := q.queue.Pops()
+
+	q.waitingMutex.Lock()
+	for _, job := range jobs {
+		delete(q.waiting, job)
+	}
+	q.waitingMutex.Unlock()
+
+	poppedJobs.With(q.algorithm).Add(float64(len(jobs)))
+	return jobs, errs
+}
+
+func (q *Instrumented) Len() int {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+	return q.queue.Len()
+}
+
+func (q *Instrumented) IsEmpty() bool {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+	return q.queue.IsEmpty()
+}
+
+// Oldest is the earliest CreatedAt among waiting jobs, false when empty
+func (q *Instrumented) Oldest() (time.Time, bool) {
+	q.waitingMutex.Lock()
+	defer q.waitingMutex.Unlock()
+
+	var oldest time.Time
+	for job := range q.waiting {
+		if oldest.IsZero() || job.CreatedAt.Before(oldest) {
+			oldest = job.CreatedAt
+		}
+	}
+	return oldest, !oldest.IsZero()
+}
+
+func (q *Instrumented) Algorithm() string {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+	return q.algorithm
+}
+
+/*
+Swap replaces the algorithm, moving every waiting job into next. Pushes and
+pops block for the move so no job is lost or seen twice, jobs already popped
+are not affected. Returns the number of jobs moved
+*/
+func (q *Instrumented) Swap(next Queue, algorithm string) int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	moved := 0
+	for !q.queue.IsEmpty() {
+		jobs, _ := q.queue.Pops()
+		if len(jobs) == 0 {
+			break
+		}
+
+		valid := make([]*Job, 0, len(jobs))
+		for _, job := range jobs {
+			if job != nil {
+				valid = append(valid, job)
+			}
+		}
+		next.Pushs(valid)
+		moved += len(valid)
+	}
+
+	swaps.With(q.algorithm, algorithm).Inc()
+	q.queue = next
+	q.algorithm = algorithm
+	return moved
+}
+
+func Instrument(q Queue, algorithm string) *Instrumented {
+	inst := &Instrumented{
+		queue:     q,
+		algorithm: algorithm,
+		waiting:   make(map[*Job]struct{}),
+	}
+
+	metrics.NewGaugeFunc("lm_queue_length", "Jobs waiting in the queue",
+		[]string{"algorithm"}, func() []metrics.Sample {
+			inst.mutex.RLock()
+			defer inst.mutex.RUnlock()
+			return []metrics.Sample{{Values: []string{inst.algorithm}, Value: float64(inst.queue.Len())}}
+		})
+
+	return inst
+}
@@ -0,0 +1,43 @@
+package queue_test
+
+import (
+	"testing"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue/algorithms"
+)
+
+func TestInstrumented_Swap(t *testing.T) {
+	q := queue.Instrument(algorithms.NewFCFSQueue(), "FCFS")
+
+	testSize := 300
+	jobs := make([]*queue.Job, testSize)
+	for i := range testSize {
+		jobs[i] = &queue.Job{ID: i}
+	}
+	q.Pushs(jobs)
+
+	if moved := q.Swap(algorithms.NewSJF(), "SJF"); moved != testSize {
+		t.Errorf("Expected %d jobs moved, got %d", testSize, moved)
+	}
+	if q.Algorithm() != "SJF" {
+		t.Errorf("Expected algorithm SJF, got %s", q.Algorithm())
+	}
+
+	seen := make(map[int]bool)
+	for !q.IsEmpty() {
+		popped, _ := q.Pops()
+		for _, job := range popped {
+			if seen[job.ID] {
+				t.Errorf("Job %d popped twice", job.ID)
+			}
+			seen[job.ID] = true
+		}
+	}
+	if len(seen) != testSize {
+		t.Errorf("Expected %d jobs after swap, got %d", testSize, len(seen))
+	}
+	if _, ok := q.Oldest(); ok {
+		t.Errorf("Expected no waiting jobs")
+	}
+}
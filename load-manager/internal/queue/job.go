@@ -1,8 +1,14 @@
 package queue
 
-import "time"
+import (
+	"sync/atomic"
+	"time"
 
-var idCounter int = -1
+	"github.com/sudo-JP/Load-Manager/common/tracing"
+)
+
+// Job ids are correlation ids across processes, handlers take them concurrently
+var idCounter atomic.Int64
 
 type JobType int
 type Operation int 
@@ -20,6 +26,31 @@ const (
 	Delete 
 )
 
+func (t JobType) String() string {
+	switch t {
+	case User:
+		return "user"
+	case Product:
+		return "product"
+	case Order:
+		return "order"
+	}
+	return "unknown"
+}
+
+func (o Operation) String() string {
+	switch o {
+	case Create:
+		return "create"
+	case Read:
+		return "read"
+	case Update:
+		return "update"
+	case Delete:
+		return "delete"
+	}
+	return "unknown"
+}
 
 type Job struct {
 	ID 			int
@@ -28,10 +59,46 @@ type Job struct {
 	Payload 	[]byte
 	Priority 	int 
 	CreatedAt 	time.Time
+	Trace 		tracing.SpanContext // span of the request that created the job
+	Reply 		chan Result // set by callers that wait for the outcome, nil otherwise
+}
+
+// Result is what the backend made of a job, Response is the reply for reads.
+// Superseded writes were never sent, a later write to the same record replaced them
+type Result struct {
+	Response 	any
+	Err 		error
+	Superseded 	bool
+}
+
+// NewReply makes the job reportable, the caller then reads job.Reply
+func (j *Job) NewReply() {
+	j.Reply = make(chan Result, 1)
+}
+
+// Complete never blocks, only the first outcome of a job is kept
+func (j *Job) Complete(resp any, err error) {
+	if j.Reply == nil {
+		return
+	}
+	select {
+	case j.Reply <- Result{Response: resp, Err: err}:
+	default:
+	}
+}
+
+// Supersede completes a write that was collapsed before dispatch
+func (j *Job) Supersede() {
+	if j.Reply == nil {
+		return
+	}
+	select {
+	case j.Reply <- Result{Superseded: true}:
+	default:
+	}
 }
 
 func GetID() int {
-	idCounter++
-	return idCounter
+	return int(idCounter.Add(1) - 1)
 }
 
@@ -0,0 +1,33 @@
+package queue_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+)
+
+func TestGetID_Concurrent(t *testing.T) {
+	const goroutines, each = 8, 1000
+	ids := make(chan int, goroutines*each)
+	var wg sync.WaitGroup
+	for range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range each {
+				ids <- queue.GetID()
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[int]bool)
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("Expected unique job ids, got %d twice", id)
+		}
+		seen[id] = true
+	}
+}
@@ -0,0 +1,196 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/metrics"
+)
+
+var limitedTotal = metrics.NewCounterVec("lm_rate_limited_total",
+	"Requests rejected by the rate limiter", "method", "resource")
+
+// Idle buckets are dropped this often, a dropped bucket comes back full
+const sweepInterval = time.Minute
+
+/*
+Rule limits requests matching Method and Resource, an empty field matches
+anything. Each client gets its own bucket of Burst tokens refilled at Rate
+per second, Burst defaults to Rate rounded up
+
+	routes:
+	  - method: POST
+	    resource: order
+	    rate: 5
+	    burst: 10
+*/
+type Rule struct {
+	Method   string  `yaml:"method"`
+	Resource string  `yaml:"resource"`
+	Rate     float64 `yaml:"rate"`
+	Burst    int     `yaml:"burst"`
+}
+
+func (r Rule) burst() int {
+	if r.Burst > 0 {
+		return r.Burst
+	}
+	return max(1, int(math.Ceil(r.Rate)))
+}
+
+func (r Rule) matches(method, resource string) bool {
+	return (r.Method == "" || r.Method == method) &&
+		(r.Resource == "" || r.Resource == resource)
+}
+
+// ValidateRules names the first offending field, e.g. routes[1].rate
+func ValidateRules(rules []Rule) error {
+	for i, rule := range rules {
+		switch rule.Method {
+		case "", "GET", "POST", "PUT", "DELETE":
+		default:
+			return fmt.Errorf("routes[%d].method: invalid method %s. Must be: GET, POST, PUT, DELETE", i, rule.Method)
+		}
+		switch rule.Resource {
+		case "", "user", "product", "order":
+		default:
+			return fmt.Errorf("routes[%d].resource: invalid resource %s. Must be: user, product, order", i, rule.Resource)
+		}
+		if rule.Rate <= 0 {
+			return fmt.Errorf("routes[%d].rate: must be positive, got %v", i, rule.Rate)
+		}
+		if rule.Burst < 0 {
+			return fmt.Errorf("routes[%d].burst: must not be negative", i)
+		}
+	}
+	return nil
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type bucketKey struct {
+	rule   int // index in Limiter.rules
+	client string
+}
+
+// Decision is what Allow made of a request, Limit is the bucket size,
+// 0 when no rule matched
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // until a token is back, when not allowed
+}
+
+/*
+Limiter keeps a token bucket per client and rule. A request is counted
+against the first rule that matches it, or the default rule. Clients are
+API key ids or IPs, whatever the caller uses to tell them apart
+*/
+type Limiter struct {
+	rules []Rule // routes first, the default last
+
+	mutex     sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func (l *Limiter) rule(method, resource string) int {
+	for i, rule := range l.rules {
+		if rule.matches(method, resource) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Full buckets hold nothing worth keeping
+func (l *Limiter) sweepLocked(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		rule := l.rules[key.rule]
+		if b.tokens+now.Sub(b.last).Seconds()*rule.Rate >= float64(rule.burst()) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Metric labels are kept to the values rules can name
+func label(value string, known ...string) string {
+	if slices.Contains(known, value) {
+		return value
+	}
+	return "other"
+}
+
+func (l *Limiter) Allow(client, method, resource string) Decision {
+	return l.AllowN(client, method, resource, 1)
+}
+
+// AllowN takes n tokens at once, for requests that carry n items. More than
+// the burst costs the whole bucket
+func (l *Limiter) AllowN(client, method, resource string, n int) Decision {
+	idx := l.rule(method, resource)
+	if idx < 0 {
+		return Decision{Allowed: true}
+	}
+	rule := l.rules[idx]
+	burst := float64(rule.burst())
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	l.sweepLocked(now)
+
+	key := bucketKey{rule: idx, client: client}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(burst, b.tokens+now.Sub(b.last).Seconds()*rule.Rate)
+	b.last = now
+
+	cost := min(float64(max(n, 1)), burst)
+	d := Decision{Limit: rule.burst()}
+	if b.tokens >= cost {
+		b.tokens -= cost
+		d.Allowed = true
+	} else {
+		d.RetryAfter = time.Duration((cost - b.tokens) / rule.Rate * float64(time.Second))
+		limitedTotal.With(label(method, "GET", "POST", "PUT", "DELETE"),
+			label(resource, "user", "product", "order")).Inc()
+	}
+	d.Remaining = int(b.tokens)
+	return d
+}
+
+// NewLimiter checks routes before def, a def with no rate only limits routes
+func NewLimiter(def Rule, routes []Rule) *Limiter {
+	rules := append([]Rule{}, routes...)
+	if def.Rate > 0 {
+		def.Method, def.Resource = "", ""
+		rules = append(rules, def)
+	}
+	return &Limiter{
+		rules:   rules,
+		buckets: make(map[bucketKey]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Enabled is false when no rule has a rate
+func (l *Limiter) Enabled() bool {
+	return len(l.rules) > 0
+}
@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func fakeClock(l *Limiter) *time.Time {
+	now := time.Unix(1000, 0)
+	l.now = func() time.Time { return now }
+	return &now
+}
+
+func TestLimiter_BurstThenRefill(t *testing.T) {
+	l := NewLimiter(Rule{Rate: 2, Burst: 3}, nil)
+	now := fakeClock(l)
+
+	for i := range 3 {
+		if d := l.Allow("a", "GET", "user"); !d.Allowed || d.Remaining != 2-i {
+			t.Fatalf("Request %d: expected allowed with %d left, got %+v", i, 2-i, d)
+		}
+	}
+	d := l.Allow("a", "GET", "user")
+	if d.Allowed || d.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("Expected rejection with 500ms retry, got %+v", d)
+	}
+
+	// Other clients have their own bucket
+	if d := l.Allow("b", "GET", "user"); !d.Allowed {
+		t.Errorf("Expected another client to be allowed")
+	}
+
+	*now = now.Add(500 * time.Millisecond)
+	if d := l.Allow("a", "GET", "user"); !d.Allowed {
+		t.Errorf("Expected a token back after 500ms, got %+v", d)
+	}
+}
+
+func TestLimiter_RouteRules(t *testing.T) {
+	l := NewLimiter(Rule{Rate: 100}, []Rule{{Method: "POST", Resource: "order", Rate: 1}})
+	fakeClock(l)
+
+	if d := l.Allow("a", "POST", "order"); !d.Allowed || d.Limit != 1 {
+		t.Fatalf("Expected first order allowed with limit 1, got %+v", d)
+	}
+	if d := l.Allow("a", "POST", "order"); d.Allowed {
+		t.Errorf("Expected second order to be limited")
+	}
+	if d := l.Allow("a", "GET", "order"); !d.Allowed || d.Limit != 100 {
+		t.Errorf("Expected reads to use the default rule, got %+v", d)
+	}
+
+	// No default, only routes are limited
+	l = NewLimiter(Rule{}, []Rule{{Resource: "user", Rate: 1}})
+	fakeClock(l)
+	for range 5 {
+		if d := l.Allow("a", "POST", "product"); !d.Allowed {
+			t.Fatalf("Expected unmatched requests to pass")
+		}
+	}
+}
+
+func TestLimiter_SweepsFullBuckets(t *testing.T) {
+	l := NewLimiter(Rule{Rate: 10}, nil)
+	now := fakeClock(l)
+
+	l.Allow("a", "GET", "user")
+	*now = now.Add(2 * sweepInterval)
+	l.Allow("b", "GET", "user")
+	if _, ok := l.buckets[bucketKey{client: "a"}]; ok {
+		t.Errorf("Expected idle bucket to be dropped")
+	}
+}
+
+func TestLimiter_AllowN(t *testing.T) {
+	l := NewLimiter(Rule{Rate: 1, Burst: 5}, nil)
+	now := fakeClock(l)
+
+	if d := l.AllowN("a", "POST", "user", 3); !d.Allowed || d.Remaining != 2 {
+		t.Fatalf("Expected 3 items allowed with 2 left, got %+v", d)
+	}
+	if d := l.AllowN("a", "POST", "user", 3); d.Allowed || d.RetryAfter != time.Second {
+		t.Errorf("Expected rejection until a third token is back, got %+v", d)
+	}
+
+	// More than the burst waits for a full bucket
+	*now = now.Add(time.Hour)
+	if d := l.AllowN("a", "POST", "user", 50); !d.Allowed || d.Remaining != 0 {
+		t.Errorf("Expected a full bucket to cover any request, got %+v", d)
+	}
+}
+
+func TestLabel(t *testing.T) {
+	if got := label("order", "user", "product", "order"); got != "order" {
+		t.Errorf("Expected a known value kept, got %s", got)
+	}
+	if got := label("wp-admin", "user", "product", "order"); got != "other" {
+		t.Errorf("Expected an unknown value as other, got %s", got)
+	}
+}
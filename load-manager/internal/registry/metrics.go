@@ -0,0 +1,54 @@
+package registry
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/metrics"
+)
+
+var breakerTransitions = metrics.NewCounterVec("lm_backend_breaker_transitions_total",
+	"Circuit breaker state changes per node", "node", "to")
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Node gauges are read from the registry on scrape so removed nodes disappear
+func (r *Registry) registerMetrics() {
+	nodeGauge := func(name, help string, value func(node *BackendNode) float64) {
+		metrics.NewGaugeFunc(name, help, []string{"node"}, func() []metrics.Sample {
+			nodes := r.All()
+			samples := make([]metrics.Sample, len(nodes))
+			for i, node := range nodes {
+				samples[i] = metrics.Sample{Values: []string{node.Addr()}, Value: value(node)}
+			}
+			return samples
+		})
+	}
+
+	nodeGauge("lm_backend_in_flight", "gRPC calls in flight per node", func(node *BackendNode) float64 {
+		return float64(atomic.LoadInt32(&node.ActiveReqCount))
+	})
+	nodeGauge("lm_backend_healthy", "1 if the last health check passed", func(node *BackendNode) float64 {
+		return boolValue(r.Healthy(node))
+	})
+	nodeGauge("lm_backend_breaker_state", "Circuit breaker state, 0 closed, 1 open, 2 half-open", func(node *BackendNode) float64 {
+		return float64(node.Breaker.State())
+	})
+	nodeGauge("lm_backend_draining", "1 if the node is draining", func(node *BackendNode) float64 {
+		return boolValue(node.State() == Draining)
+	})
+	nodeGauge("lm_backend_ramp", "Slow start share between 0 and 1", func(node *BackendNode) float64 {
+		return node.Ramp(time.Now())
+	})
+	nodeGauge("lm_backend_concurrency_limit", "Adaptive cap on calls in flight, 0 when unbounded", func(node *BackendNode) float64 {
+		return float64(node.Limiter.Limit())
+	})
+	nodeGauge("lm_backend_weight", "Configured node weight", func(node *BackendNode) float64 {
+		return float64(atomic.LoadInt32(&node.Weight))
+	})
+}
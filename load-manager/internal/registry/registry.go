@@ -4,38 +4,197 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/breaker"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/concurrency"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
+type NodeState int32
+
+const (
+	Active NodeState = iota
+	Draining // in-flight calls finish, nothing new is selected
+)
+
+func (s NodeState) String() string {
+	switch s {
+	case Active:
+		return "active"
+	case Draining:
+		return "draining"
+	}
+	return "unknown"
+}
+
+func ParseNodeState(s string) (NodeState, error) {
+	switch s {
+	case "active":
+		return Active, nil
+	case "draining":
+		return Draining, nil
+	}
+	return Active, fmt.Errorf("invalid node state %s. Must be: active, draining", s)
+}
+
 type BackendNode struct {
 	ID 				int 
 	Host 			string 
 	Port 			int	
 	Health 			bool
 	ActiveReqCount 	int32	
+	Weight 			int32 // relative share for weighted selectors, use atomic
+	Labels 			map[string]string // replaced on update, never mutated
+	Breaker 		*breaker.Breaker
+	Limiter 		*concurrency.Limiter // caps calls in flight
+
+	state 			atomic.Int32
+	rampStart 		atomic.Int64 // unix nano, slow start begins here
+	slowStart 		time.Duration
+}
+
+func (n *BackendNode) Addr() string {
+	return fmt.Sprintf("%s:%d", n.Host, n.Port)
+}
+
+func (n *BackendNode) State() NodeState {
+	return NodeState(n.state.Load())
+}
+
+// Ramp is the fraction of a full traffic share the node should get,
+// growing linearly from 0 to 1 over the slow start window
+func (n *BackendNode) Ramp(now time.Time) float64 {
+	if n.slowStart <= 0 {
+		return 1
+	}
+
+	elapsed := now.Sub(time.Unix(0, n.rampStart.Load()))
+	if elapsed >= n.slowStart {
+		return 1
+	}
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(elapsed) / float64(n.slowStart)
+}
+
+func (n *BackendNode) startRamp() {
+	n.rampStart.Store(time.Now().UnixNano())
 }
 
 type Registry struct {
 	Nodes 	[]*BackendNode
 	mutex 	sync.RWMutex
 	nextID 	int // For setting backend id 
+	breakerConf breaker.Config
+	limitConf 	concurrency.Config
+	slowStart 	time.Duration
+	creds 		credentials.TransportCredentials // for health checks, nil is plaintext
+}
+
+// Health checks dial with the same credentials as the worker
+func (r *Registry) SetTransportCredentials(creds credentials.TransportCredentials) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.creds = creds
 }
 
-func (r *Registry) Add(host string, port int) {
+// Only affects nodes added afterwards
+func (r *Registry) SetSlowStart(window time.Duration) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	r.slowStart = window
+}
+
+// Only affects nodes added afterwards
+func (r *Registry) SetBreakerConfig(conf breaker.Config) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.breakerConf = conf
+}
+
+// Only affects nodes added afterwards
+func (r *Registry) SetLimiterConfig(conf concurrency.Config) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.limitConf = conf
+}
+
+func (r *Registry) Add(host string, port int) *BackendNode {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.addLocked(host, port)
+}
+
+// AddIfAbsent returns false and the existing node if host:port is already registered
+func (r *Registry) AddIfAbsent(host string, port int) (*BackendNode, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, node := range r.Nodes {
+		if node.Host == host && node.Port == port {
+			return node, false
+		}
+	}
+	return r.addLocked(host, port), true
+}
+
+// Must hold lock
+func (r *Registry) addLocked(host string, port int) *BackendNode {
 	node := BackendNode{
 		ID: 			r.nextID,
 		Host: 			host, 
 		Health: 		false, 
 		Port: 			port,
 		ActiveReqCount: 0,
+		Weight: 		1,
+		Labels: 		map[string]string{},
+		Breaker: 		breaker.NewBreaker(r.breakerConf),
+		Limiter: 		concurrency.NewLimiter(r.limitConf),
+		slowStart: 		r.slowStart,
 	}
+	node.startRamp()
+
+	// Recovered nodes ramp back up too
+	node.Breaker.OnChange(func(from, to breaker.State) {
+		breakerTransitions.With(node.Addr(), to.String()).Inc()
+		if from == breaker.HalfOpen && to == breaker.Closed {
+			node.startRamp()
+		}
+	})
+
 	r.nextID++
 	r.Nodes = append(r.Nodes, &node)
+	return &node
+}
+
+func (r *Registry) Get(id int) *BackendNode {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, node := range r.Nodes {
+		if node.ID == id {
+			return node
+		}
+	}
+	return nil
+}
+
+func (r *Registry) Find(host string, port int) *BackendNode {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, node := range r.Nodes {
+		if node.Host == host && node.Port == port {
+			return node
+		}
+	}
+	return nil
 }
 
 func (r *Registry) All() []*BackendNode {
@@ -47,6 +206,21 @@ func (r *Registry) All() []*BackendNode {
 	return result
 }
 
+// Available returns nodes that are not draining and whose circuit breaker
+// would let a call through
+func (r *Registry) Available() []*BackendNode {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	result := make([]*BackendNode, 0, len(r.Nodes))
+	for _, node := range r.Nodes {
+		if node.State() == Active && node.Breaker.Ready() {
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
 func (r *Registry) SetHealth(id int, healthy bool) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
@@ -59,6 +233,51 @@ func (r *Registry) SetHealth(id int, healthy bool) {
 	}
 }
 
+// Healthy is the node's last health check, taken under the lock SetHealth writes it with
+func (r *Registry) Healthy(node *BackendNode) bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return node.Health
+}
+
+// Labels of the node, taken under the lock Update swaps them with
+func (r *Registry) Labels(node *BackendNode) map[string]string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return node.Labels
+}
+
+func (r *Registry) Update(id int, weight int, labels map[string]string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, node := range r.Nodes {
+		if node.ID == id {
+			atomic.StoreInt32(&node.Weight, int32(weight))
+			node.Labels = labels
+			return true
+		}
+	}
+	return false
+}
+
+// Going back to active starts a new slow start window
+func (r *Registry) SetState(id int, state NodeState) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, node := range r.Nodes {
+		if node.ID == id {
+			old := NodeState(node.state.Swap(int32(state)))
+			if old == Draining && state == Active {
+				node.startRamp()
+			}
+			return true
+		}
+	}
+	return false
+}
+
 func (r *Registry) Remove(id int) bool {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
@@ -85,25 +304,43 @@ func (r *Registry) HealthCheckLoop() {
 	}
 }
 
+// A node is healthy when a connection, TLS handshake included, is ready within 2s
 func (r *Registry) checkHealth(node *BackendNode) bool {
-	_, cancel := context.WithTimeout(context.Background(), 2 * time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 2 * time.Second)
 	defer cancel()
 
-	addr := fmt.Sprintf("%s:%d", node.Host, node.Port)
-	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	r.mutex.RLock()
+	creds := r.creds
+	r.mutex.RUnlock()
+	if creds == nil {
+		creds = insecure.NewCredentials()
+	}
+
+	conn, err := grpc.NewClient(node.Addr(), grpc.WithTransportCredentials(creds))
 	if err != nil {
 		return false 
 	}
+	defer conn.Close()
 
-	defer func() {
-		err = conn.Close()
-	}()
-	return true 
+	conn.Connect()
+	for {
+		state := conn.GetState()
+		if state == connectivity.Ready {
+			return true
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return false // timed out
+		}
+	}
 }
 
 func NewRegistry() *Registry {
-	return &Registry{
+	r := &Registry{
 		Nodes: make([]*BackendNode, 0),
 		nextID: 0, 
+		breakerConf: breaker.DefaultConfig(),
+		limitConf: 	 concurrency.DefaultConfig(),
 	}
+	r.registerMetrics()
+	return r
 }
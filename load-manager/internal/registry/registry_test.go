@@ -0,0 +1,106 @@
+package registry
+
+import (
+	"math"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sudo-JP/Load-Manager/common/certs"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+func TestCheckHealth_TLS(t *testing.T) {
+	dir := t.TempDir()
+	if err := certs.GenerateTestCA(dir, "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	serverStore, err := certs.NewStore(certs.Files{
+		Cert: filepath.Join(dir, certs.TestServerCert),
+		Key:  filepath.Join(dir, certs.TestServerKey),
+	}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientStore, err := certs.NewStore(certs.Files{CA: filepath.Join(dir, certs.TestCAFile)}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(grpc.Creds(credentials.NewTLS(serverStore.ServerConfig())))
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	r := NewRegistry()
+	node := r.Add("127.0.0.1", lis.Addr().(*net.TCPAddr).Port)
+	if r.checkHealth(node) {
+		t.Errorf("Expected a plaintext probe of a TLS-only node to fail")
+	}
+	r.SetTransportCredentials(credentials.NewTLS(clientStore.ClientConfig("")))
+	if !r.checkHealth(node) {
+		t.Errorf("Expected the probe with TLS credentials to pass")
+	}
+}
+
+func TestRamp(t *testing.T) {
+	r := NewRegistry()
+	r.SetSlowStart(time.Minute)
+	node := r.Add("localhost", 50001)
+	start := time.Unix(0, node.rampStart.Load())
+
+	for _, tt := range []struct {
+		after time.Duration
+		ramp  float64
+	}{
+		{-time.Second, 0},
+		{0, 0},
+		{15 * time.Second, 0.25},
+		{30 * time.Second, 0.5},
+		{45 * time.Second, 0.75},
+		{time.Minute, 1},
+		{time.Hour, 1},
+	} {
+		if got := node.Ramp(start.Add(tt.after)); math.Abs(got-tt.ramp) > 1e-9 {
+			t.Errorf("Ramp after %v: expected %v, got %v", tt.after, tt.ramp, got)
+		}
+	}
+
+	if ramp := r.Add("localhost", 50002).Ramp(start); ramp != 0 {
+		t.Errorf("Expected a new node to start at 0, got %v", ramp)
+	}
+	r.SetSlowStart(0)
+	if ramp := r.Add("localhost", 50003).Ramp(start); ramp != 1 {
+		t.Errorf("Expected full share without slow start, got %v", ramp)
+	}
+}
+
+func TestAvailable_SkipsDraining(t *testing.T) {
+	r := NewRegistry()
+	r.SetSlowStart(time.Minute)
+	a := r.Add("localhost", 50001)
+	b := r.Add("localhost", 50002)
+
+	r.SetState(a.ID, Draining)
+	if nodes := r.Available(); len(nodes) != 1 || nodes[0] != b {
+		t.Fatalf("Expected only the active node available, got %d nodes", len(nodes))
+	}
+	if len(r.All()) != 2 {
+		t.Errorf("Expected a draining node to stay registered")
+	}
+
+	// Back to active it ramps up again from 0
+	a.rampStart.Store(0)
+	r.SetState(a.ID, Active)
+	if len(r.Available()) != 2 {
+		t.Errorf("Expected the node available again")
+	}
+	if ramp := a.Ramp(time.Now()); ramp > 0.1 {
+		t.Errorf("Expected a new slow start window, ramp is %v", ramp)
+	}
+}
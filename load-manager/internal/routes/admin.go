@@ -0,0 +1,179 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/registry"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/scheduler"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/worker"
+)
+
+type BreakerDTO struct {
+	State    string     `json:"state"`
+	Failures int        `json:"failures"`
+	OpenedAt *time.Time `json:"opened_at,omitempty"`
+}
+
+type NodeDTO struct {
+	ID             int               `json:"id"`
+	Host           string            `json:"host"`
+	Port           int               `json:"port"`
+	Health         bool              `json:"health"`
+	State          string            `json:"state"`
+	Ramp           float64           `json:"ramp"`
+	ActiveReqCount int32             `json:"active_requests"`
+	Limit          int               `json:"concurrency_limit,omitempty"` // 0 is unbounded
+	Weight         int32             `json:"weight"`
+	Labels         map[string]string `json:"labels"`
+	Breaker        BreakerDTO        `json:"breaker"`
+}
+
+func toNodeDTO(reg *registry.Registry, node *registry.BackendNode) NodeDTO {
+	stats := node.Breaker.Stats()
+	breaker := BreakerDTO{
+		State:    stats.State.String(),
+		Failures: stats.Failures,
+	}
+	if !stats.OpenedAt.IsZero() {
+		breaker.OpenedAt = &stats.OpenedAt
+	}
+
+	return NodeDTO{
+		ID:             node.ID,
+		Host:           node.Host,
+		Port:           node.Port,
+		Health:         reg.Healthy(node),
+		State:          node.State().String(),
+		Ramp:           node.Ramp(time.Now()),
+		ActiveReqCount: atomic.LoadInt32(&node.ActiveReqCount),
+		Limit:          node.Limiter.Limit(),
+		Weight:         atomic.LoadInt32(&node.Weight),
+		Labels:         reg.Labels(node),
+		Breaker:        breaker,
+	}
+}
+
+func ListNodes(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		nodes := reg.All()
+
+		result := make([]NodeDTO, len(nodes))
+		for i, node := range nodes {
+			result[i] = toNodeDTO(reg, node)
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+type AddNodeDTO struct {
+	Host string `json:"host" binding:"required"`
+	Port int    `json:"port" binding:"required,min=1,max=65535"`
+}
+
+func AddNode(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var dto AddNodeDTO
+
+		if err := c.ShouldBindJSON(&dto); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		node, added := reg.AddIfAbsent(dto.Host, dto.Port)
+		if !added {
+			c.JSON(http.StatusConflict, gin.H{"error": "node already registered"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, toNodeDTO(reg, node))
+	}
+}
+
+// Blocks until the node is drained or drainTimeout passes
+func RemoveNode(wrk *worker.Worker, drainTimeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid node id"})
+			return
+		}
+
+		if err := wrk.RemoveNode(id, drainTimeout); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Status(http.StatusOK)
+	}
+}
+
+type NodeStateDTO struct {
+	State string `json:"state" binding:"required"`
+}
+
+// Draining keeps the node registered but stops new jobs going to it
+func SetNodeState(reg *registry.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid node id"})
+			return
+		}
+
+		var dto NodeStateDTO
+		if err := c.ShouldBindJSON(&dto); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		state, err := registry.ParseNodeState(dto.State)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !reg.SetState(id, state) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, toNodeDTO(reg, reg.Get(id)))
+	}
+}
+
+func GetScheduler(sch *scheduler.Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, sch.Config())
+	}
+}
+
+// Empty fields are left unchanged, queued jobs move to a new queue algorithm
+func UpdateScheduler(sch *scheduler.Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var dto scheduler.Config
+
+		if err := c.ShouldBindJSON(&dto); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		conf, err := sch.Apply(dto)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, conf)
+	}
+}
@@ -0,0 +1,62 @@
+package routes
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/auth"
+)
+
+// APIKey reads "Authorization: Bearer <key>" or "X-API-Key: <key>"
+func APIKey(header http.Header) string {
+	if bearer, ok := strings.CutPrefix(header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(bearer)
+	}
+	return header.Get("X-API-Key")
+}
+
+// /balancer/users:bulk, /balancer/user and /single/users/3 are all user.
+// false for anything else, proxy mode forwards any path under /single
+func resourceOf(urlPath string) (string, bool) {
+	segments := strings.Split(strings.Trim(urlPath, "/"), "/")
+	if len(segments) < 2 {
+		return "", false
+	}
+	name, _, _ := strings.Cut(segments[1], ":")
+	name = strings.TrimSuffix(name, "s")
+	return name, auth.IsResource(name)
+}
+
+// Auth rejects requests without a key allowed for the route's resource,
+// GET needs the read scope and everything else write. Paths that name no
+// resource get 404
+func Auth(keys *auth.Keys) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		write := c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead
+		resource, ok := resourceOf(c.Request.URL.Path)
+		if !ok {
+			// Keys are scoped to resources, there is nothing to check others against
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "unknown resource"})
+			return
+		}
+
+		id, err := keys.Authorize(APIKey(c.Request.Header), resource, write)
+		if err != nil {
+			status := http.StatusUnauthorized
+			if errors.Is(err, auth.ErrForbidden) {
+				status = http.StatusForbidden
+			}
+			slog.Warn("Rejected request", "key_id", id, "method", c.Request.Method,
+				"path", c.Request.URL.Path, "client", c.ClientIP(), "reason", err)
+			c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Set("key_id", id)
+		c.Set("max_priority", keys.MaxPriority(id))
+		c.Next()
+	}
+}
@@ -0,0 +1,61 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/auth"
+)
+
+func TestResourceOf(t *testing.T) {
+	tests := []struct {
+		path     string
+		resource string
+		ok       bool
+	}{
+		{"/balancer/user", "user", true},
+		{"/balancer/users:bulk", "user", true},
+		{"/single/orders/3", "order", true},
+		{"/single/product", "product", true},
+		{"/single/admin/keys", "admin", false},
+		{"/single/", "", false},
+		{"/single", "", false},
+	}
+	for _, tt := range tests {
+		resource, ok := resourceOf(tt.path)
+		if resource != tt.resource || ok != tt.ok {
+			t.Errorf("%s: expected %q %v, got %q %v", tt.path, tt.resource, tt.ok, resource, ok)
+		}
+	}
+}
+
+func TestAuth_UnknownResource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	os.WriteFile(path, []byte("keys:\n  - id: all\n    key: secret\n    scopes: [read, write]\n"), 0o600)
+	keys, err := auth.NewKeys(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Any("/single/*path", Auth(keys), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for path, code := range map[string]int{
+		"/single/users/1":  http.StatusOK,
+		"/single/internal": http.StatusNotFound,
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-API-Key", "secret")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != code {
+			t.Errorf("%s: expected %d, got %d", path, code, rec.Code)
+		}
+	}
+}
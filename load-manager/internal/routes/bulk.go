@@ -0,0 +1,174 @@
+package routes
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/sudo-JP/Load-Manager/common/tracing"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/batcher"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+)
+
+/*
+Bulk routes take "collection:verb" paths like /users:bulk. Gin reads the
+colon as the start of a parameter, so they are registered as /users:verb
+and the handler checks the verb
+*/
+const bulkVerb = ":bulk"
+
+type BulkItemError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+type BulkResponse struct {
+	Accepted int             `json:"accepted"`
+	Rejected int             `json:"rejected"`
+	Errors   []BulkItemError `json:"errors,omitempty"`
+	Error    string          `json:"error,omitempty"` // body could not be read past Accepted + Rejected items
+}
+
+/*
+decodeItems calls fn with each item of a JSON array or an NDJSON stream.
+Items are read one at a time, so large imports are never held in memory.
+A syntax error ends the stream, there is no telling where the next item starts,
+so does an error from fn
+*/
+func decodeItems(body io.Reader, fn func(idx int, item json.RawMessage) error) error {
+	r := bufio.NewReader(body)
+
+	// Skip leading whitespace to tell an array from NDJSON
+	var first byte
+	for {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			return errors.New("empty body")
+		}
+		if err != nil {
+			return err
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			first = b
+			break
+		}
+	}
+	if err := r.UnreadByte(); err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(r)
+	isArray := first == '['
+	if isArray {
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+	}
+
+	for idx := 0; ; idx++ {
+		if isArray && !dec.More() {
+			_, err := dec.Token() // closing bracket
+			return err
+		}
+		var item json.RawMessage
+		if err := dec.Decode(&item); err != nil {
+			if err == io.EOF && !isArray {
+				return nil
+			}
+			return fmt.Errorf("item %d: %w", idx, err)
+		}
+		if err := fn(idx, item); err != nil {
+			return fmt.Errorf("item %d: %w", idx, err)
+		}
+	}
+}
+
+/*
+bulkCreate validates every item like the single create route, valid items
+go to add as they are read. 200 means every item was accepted and 400 that
+none was. Otherwise some items were queued while others were rejected or
+never read, that is 207, so a client retrying on 4xx does not queue the
+accepted ones twice. The request's rate limit token covers the first
+accepted item, each further one takes another and reading stops at the
+first item over the limit
+*/
+func bulkCreate[T any](resource queue.JobType, add func(*queue.Job)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Param("verb") != bulkVerb {
+			c.JSON(http.StatusNotFound, gin.H{"error": "unknown method " + c.Param("verb")})
+			return
+		}
+
+		trace := tracing.SpanFromContext(c.Request.Context()).Context()
+		var resp BulkResponse
+		reject := func(idx int, err error) {
+			resp.Rejected++
+			resp.Errors = append(resp.Errors, BulkItemError{Index: idx, Error: err.Error()})
+		}
+
+		err := decodeItems(c.Request.Body, func(idx int, item json.RawMessage) error {
+			var dto T
+			if err := json.Unmarshal(item, &dto); err != nil {
+				reject(idx, err)
+				return nil
+			}
+			if err := binding.Validator.ValidateStruct(&dto); err != nil {
+				reject(idx, err)
+				return nil
+			}
+
+			payload, err := json.Marshal(dto)
+			if err != nil {
+				reject(idx, err)
+				return nil
+			}
+			if resp.Accepted > 0 && !chargeItem(c) {
+				return errRateLimited
+			}
+			add(&queue.Job{
+				ID:        queue.GetID(),
+				Resource:  resource,
+				CRUD:      queue.Create,
+				Payload:   payload,
+				Priority:  priority(c),
+				CreatedAt: time.Now(),
+				Trace:     trace,
+			})
+			resp.Accepted++
+			return nil
+		})
+
+		if err != nil {
+			resp.Error = err.Error()
+		}
+		switch {
+		case resp.Accepted == 0:
+			if resp.Error == "" && resp.Rejected == 0 {
+				resp.Error = "no items"
+			}
+			c.JSON(http.StatusBadRequest, resp)
+		case err != nil || resp.Rejected > 0:
+			c.JSON(http.StatusMultiStatus, resp)
+		default:
+			c.JSON(http.StatusOK, resp)
+		}
+	}
+}
+
+func BulkCreateUsers(batch *batcher.Batcher) gin.HandlerFunc {
+	return bulkCreate[CreateUserDTO](queue.User, batch.AddUser)
+}
+
+func BulkCreateProducts(batch *batcher.Batcher) gin.HandlerFunc {
+	return bulkCreate[CreateProductDTO](queue.Product, batch.AddProduct)
+}
+
+func BulkCreateOrders(batch *batcher.Batcher) gin.HandlerFunc {
+	return bulkCreate[CreateOrderDTO](queue.Order, batch.AddOrder)
+}
@@ -0,0 +1,144 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/ratelimit"
+)
+
+func collect(t *testing.T, body string) ([]string, error) {
+	t.Helper()
+	var items []string
+	err := decodeItems(strings.NewReader(body), func(idx int, item json.RawMessage) error {
+		if idx != len(items) {
+			t.Fatalf("Expected index %d, got %d", len(items), idx)
+		}
+		items = append(items, string(item))
+		return nil
+	})
+	return items, err
+}
+
+func TestDecodeItems_ArrayAndNDJSON(t *testing.T) {
+	for _, body := range []string{
+		` [{"a":1}, {"a":2}, 3]`,
+		"{\"a\":1}\n{\"a\":2}\n3\n",
+	} {
+		items, err := collect(t, body)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", body, err)
+		}
+		if len(items) != 3 || items[1] != `{"a":2}` {
+			t.Errorf("Expected 3 items from %q, got %v", body, items)
+		}
+	}
+}
+
+func TestDecodeItems_SyntaxErrorStops(t *testing.T) {
+	items, err := collect(t, "{\"a\":1}\n{\"a\":\n")
+	if err == nil || !strings.HasPrefix(err.Error(), "item 1:") {
+		t.Errorf("Expected an error naming item 1, got %v", err)
+	}
+	if len(items) != 1 {
+		t.Errorf("Expected the item before the error, got %v", items)
+	}
+
+	if _, err := collect(t, "  \n"); err == nil {
+		t.Errorf("Expected an error for an empty body")
+	}
+}
+
+func postBulk(t *testing.T, path, body string) (int, BulkResponse, []*queue.Job) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	var added []*queue.Job
+	r := gin.New()
+	r.POST("/users:verb", bulkCreate[CreateUserDTO](queue.User, func(job *queue.Job) {
+		added = append(added, job)
+	}))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	var resp BulkResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp, added
+}
+
+func TestBulkCreate(t *testing.T) {
+	const (
+		ok  = `{"name":"a","email":"a@x.io","password":"12345678"}`
+		bad = `{"name":"b","email":"nope","password":"12345678"}`
+	)
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		code     int
+		accepted int
+		errors   []int // indexes of rejected items
+	}{
+		{"all accepted", "/users:bulk", "[" + ok + "," + ok + "]", http.StatusOK, 2, nil},
+		{"some rejected", "/users:bulk", ok + "\n" + bad + "\n" + ok + "\n", http.StatusMultiStatus, 2, []int{1}},
+		{"syntax error after accepted items", "/users:bulk", ok + "\n{\"name\":\n", http.StatusMultiStatus, 1, nil},
+		{"syntax error first", "/users:bulk", "{\"name\":\n", http.StatusBadRequest, 0, nil},
+		{"all rejected", "/users:bulk", "[" + bad + "]", http.StatusBadRequest, 0, []int{0}},
+		{"no items", "/users:bulk", "[]", http.StatusBadRequest, 0, nil},
+		{"unknown verb", "/users:import", "[" + ok + "]", http.StatusNotFound, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, resp, added := postBulk(t, tt.path, tt.body)
+			if code != tt.code {
+				t.Errorf("Expected status %d, got %d", tt.code, code)
+			}
+			if resp.Accepted != tt.accepted || len(added) != tt.accepted {
+				t.Errorf("Expected %d accepted, response says %d and %d were queued",
+					tt.accepted, resp.Accepted, len(added))
+			}
+			var rejected []int
+			for _, e := range resp.Errors {
+				rejected = append(rejected, e.Index)
+			}
+			if !slices.Equal(rejected, tt.errors) {
+				t.Errorf("Expected rejected items %v, got %v", tt.errors, rejected)
+			}
+		})
+	}
+}
+
+func TestBulkCreate_ChargesPerItem(t *testing.T) {
+	const ok = `{"name":"a","email":"a@x.io","password":"12345678"}`
+	gin.SetMode(gin.TestMode)
+	var added int
+	r := gin.New()
+	limiter := ratelimit.NewLimiter(ratelimit.Rule{Rate: 1, Burst: 3}, nil)
+	r.POST("/balancer/users:verb", RateLimit(limiter), bulkCreate[CreateUserDTO](queue.User, func(job *queue.Job) {
+		added++
+	}))
+	post := func() (int, BulkResponse) {
+		w := httptest.NewRecorder()
+		body := strings.Repeat(ok+"\n", 5)
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/balancer/users:bulk", strings.NewReader(body)))
+		var resp BulkResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	code, resp := post()
+	if code != http.StatusMultiStatus || resp.Accepted != 3 || added != 3 {
+		t.Errorf("Expected the burst of 3 items accepted, got %d with %d accepted", code, resp.Accepted)
+	}
+	if !strings.Contains(resp.Error, "item 3: rate limit exceeded") {
+		t.Errorf("Expected reading to stop at item 3, got %q", resp.Error)
+	}
+	if code, _ := post(); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the next request limited, got %d", code)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sudo-JP/Load-Manager/common/tracing"
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/batcher"
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
 )
@@ -35,8 +36,9 @@ func CreateOrder(batch *batcher.Batcher) gin.HandlerFunc {
 			Resource:  queue.Order,
 			CRUD:      queue.Create,
 			Payload:   payload,
-			Priority:  0,
+			Priority:  priority(c),
 			CreatedAt: time.Now(),
+			Trace:     tracing.SpanFromContext(c.Request.Context()).Context(),
 		}
 
 		batch.AddOrder(job)
@@ -71,8 +73,9 @@ func GetOrder(batch *batcher.Batcher) gin.HandlerFunc {
 			Resource:  queue.Order,
 			CRUD:      queue.Read,
 			Payload:   payload,
-			Priority:  0,
+			Priority:  priority(c),
 			CreatedAt: time.Now(),
+			Trace:     tracing.SpanFromContext(c.Request.Context()).Context(),
 		}
 
 		batch.AddOrder(job)
@@ -104,8 +107,9 @@ func UpdateOrder(batch *batcher.Batcher) gin.HandlerFunc {
 			Resource:  queue.Order,
 			CRUD:      queue.Update,
 			Payload:   payload,
-			Priority:  0,
+			Priority:  priority(c),
 			CreatedAt: time.Now(),
+			Trace:     tracing.SpanFromContext(c.Request.Context()).Context(),
 		}
 
 		batch.AddOrder(job)
@@ -140,8 +144,9 @@ func DeleteOrder(batch *batcher.Batcher) gin.HandlerFunc {
 			Resource:  queue.Order,
 			CRUD:      queue.Delete,
 			Payload:   payload,
-			Priority:  0,
+			Priority:  priority(c),
 			CreatedAt: time.Now(),
+			Trace:     tracing.SpanFromContext(c.Request.Context()).Context(),
 		}
 
 		batch.AddOrder(job)
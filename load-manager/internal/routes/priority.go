@@ -0,0 +1,54 @@
+package routes
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/admission"
+)
+
+// Higher is more important, admission control sheds lower priorities first
+const PriorityHeader = "X-Priority"
+
+/*
+Priority reads the X-Priority header for the jobs of the request, 0 when
+absent. Anyone can send the header, so values over maxPriority are lowered
+to it, or to the key's max_priority when Auth runs after
+*/
+func Priority(maxPriority int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("max_priority", maxPriority)
+		v := c.GetHeader(PriorityHeader)
+		if v == "" {
+			c.Next()
+			return
+		}
+		p, err := strconv.Atoi(v)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid " + PriorityHeader + " " + v})
+			return
+		}
+		c.Set("priority", p)
+		c.Next()
+	}
+}
+
+func priority(c *gin.Context) int {
+	return min(c.GetInt("priority"), c.GetInt("max_priority"))
+}
+
+// Admission turns requests away with 503 while the queue is shedding load
+func Admission(ctrl *admission.Controller, retryAfter time.Duration) gin.HandlerFunc {
+	retry := strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+	return func(c *gin.Context) {
+		if !ctrl.Admit(priority(c)) {
+			c.Header("Retry-After", retry)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "overloaded, retry later"})
+			return
+		}
+		c.Next()
+	}
+}
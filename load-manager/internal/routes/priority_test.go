@@ -0,0 +1,64 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/auth"
+)
+
+func TestPriority_Capped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	os.WriteFile(path, []byte(`
+keys:
+  - id: ops
+    key: ops-secret
+    scopes: [read]
+    max_priority: 7
+  - id: batch
+    key: batch-secret
+    scopes: [read]
+`), 0o600)
+	keys, err := auth.NewKeys(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	echo := func(c *gin.Context) { c.String(http.StatusOK, strconv.Itoa(priority(c))) }
+	router := gin.New()
+	router.GET("/open/users", Priority(2), echo)
+	router.GET("/keyed/users", Priority(2), Auth(keys), echo)
+
+	tests := []struct {
+		path, key, header, want string
+	}{
+		{"/open/users", "", "", "0"},
+		{"/open/users", "", "1", "1"},
+		{"/open/users", "", "100", "2"}, // capped without a key
+		{"/open/users", "", "-3", "-3"}, // lowering is always allowed
+		{"/keyed/users", "ops-secret", "5", "5"},
+		{"/keyed/users", "ops-secret", "100", "7"},
+		{"/keyed/users", "batch-secret", "5", "0"}, // key without max_priority
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.key != "" {
+			req.Header.Set("X-API-Key", tt.key)
+		}
+		if tt.header != "" {
+			req.Header.Set(PriorityHeader, tt.header)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Body.String() != tt.want {
+			t.Errorf("%s %s X-Priority %q: expected priority %s, got %q", tt.path, tt.key, tt.header, tt.want, rec.Body.String())
+		}
+	}
+}
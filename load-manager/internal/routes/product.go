@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sudo-JP/Load-Manager/common/tracing"
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/batcher"
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
 )
@@ -34,8 +35,9 @@ func CreateProduct(batch *batcher.Batcher) gin.HandlerFunc {
 			Resource:  queue.Product,
 			CRUD:      queue.Create,
 			Payload:   payload,
-			Priority:  0,
+			Priority:  priority(c),
 			CreatedAt: time.Now(),
+			Trace:     tracing.SpanFromContext(c.Request.Context()).Context(),
 		}
 
 		batch.AddProduct(job)
@@ -70,8 +72,9 @@ func GetProduct(batch *batcher.Batcher) gin.HandlerFunc {
 			Resource:  queue.Product,
 			CRUD:      queue.Read,
 			Payload:   payload,
-			Priority:  0,
+			Priority:  priority(c),
 			CreatedAt: time.Now(),
+			Trace:     tracing.SpanFromContext(c.Request.Context()).Context(),
 		}
 
 		batch.AddProduct(job)
@@ -104,8 +107,9 @@ func UpdateProduct(batch *batcher.Batcher) gin.HandlerFunc {
 			Resource:  queue.Product,
 			CRUD:      queue.Update,
 			Payload:   payload,
-			Priority:  0,
+			Priority:  priority(c),
 			CreatedAt: time.Now(),
+			Trace:     tracing.SpanFromContext(c.Request.Context()).Context(),
 		}
 
 		batch.AddProduct(job)
@@ -140,8 +144,9 @@ func DeleteProduct(batch *batcher.Batcher) gin.HandlerFunc {
 			Resource:  queue.Product,
 			CRUD:      queue.Delete,
 			Payload:   payload,
-			Priority:  0,
+			Priority:  priority(c),
 			CreatedAt: time.Now(),
+			Trace:     tracing.SpanFromContext(c.Request.Context()).Context(),
 		}
 
 		batch.AddProduct(job)
@@ -0,0 +1,66 @@
+package routes
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/ratelimit"
+)
+
+var errRateLimited = errors.New("rate limit exceeded")
+
+/*
+RateLimit answers 429 once a client used up its bucket. Clients are API
+key ids when Auth runs first, the connection's IP otherwise, forwarded
+headers are ignored since anyone can set them. Handlers that take several
+items per request charge the others with chargeItem
+*/
+func RateLimit(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client := "ip:" + c.RemoteIP()
+		if id := c.GetString("key_id"); id != "" {
+			client = "key:" + id
+		}
+
+		resource, _ := resourceOf(c.Request.URL.Path)
+		d := limiter.Allow(client, c.Request.Method, resource)
+		if d.Limit == 0 {
+			c.Next() // no rule for this route
+			return
+		}
+
+		setRateHeaders(c, d)
+		if !d.Allowed {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": errRateLimited.Error()})
+			return
+		}
+		c.Set("charge_item", func() ratelimit.Decision {
+			return limiter.Allow(client, c.Request.Method, resource)
+		})
+		c.Next()
+	}
+}
+
+func setRateHeaders(c *gin.Context, d ratelimit.Decision) {
+	c.Header("X-RateLimit-Limit", strconv.Itoa(d.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	if !d.Allowed {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(d.RetryAfter.Seconds()))))
+	}
+}
+
+// chargeItem takes a token for one more item of the request, true when
+// there was one or no rule applies
+func chargeItem(c *gin.Context) bool {
+	v, _ := c.Get("charge_item")
+	charge, ok := v.(func() ratelimit.Decision)
+	if !ok {
+		return true
+	}
+	d := charge()
+	setRateHeaders(c, d)
+	return d.Allowed
+}
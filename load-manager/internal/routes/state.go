@@ -0,0 +1,80 @@
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/batcher"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/registry"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/scheduler"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/worker"
+)
+
+type QueueStateDTO struct {
+	Length      int      `json:"length"`
+	OldestAgeMs *float64 `json:"oldest_job_age_ms"` // null when empty
+}
+
+type WorkersStateDTO struct {
+	Total      int                      `json:"total"`
+	Busy       int                      `json:"busy"`
+	Stopped    bool                     `json:"stopped"`
+	Goroutines []worker.GoroutineStatus `json:"goroutines"`
+}
+
+type StateDTO struct {
+	Time    time.Time        `json:"time"`
+	Config  scheduler.Config `json:"config"`
+	Queue   QueueStateDTO    `json:"queue"`
+	Batcher map[string]int   `json:"batcher"`
+	Nodes   []NodeDTO        `json:"nodes"`
+	Workers WorkersStateDTO  `json:"workers"`
+}
+
+// State is a point in time snapshot of the scheduler for debugging runs
+func State(sch *scheduler.Scheduler, q *queue.Instrumented, bat *batcher.Batcher,
+	reg *registry.Registry, wrk *worker.Worker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := time.Now()
+
+		queueState := QueueStateDTO{Length: q.Len()}
+		if oldest, ok := q.Oldest(); ok {
+			age := float64(now.Sub(oldest).Microseconds()) / 1000
+			queueState.OldestAgeMs = &age
+		}
+
+		pending := make(map[string]int)
+		for resource, n := range bat.Pending() {
+			pending[resource.String()] = n
+		}
+
+		nodes := reg.All()
+		nodeDTOs := make([]NodeDTO, len(nodes))
+		for i, node := range nodes {
+			nodeDTOs[i] = toNodeDTO(reg, node)
+		}
+
+		goroutines := wrk.Status()
+		workers := WorkersStateDTO{
+			Total:      len(goroutines),
+			Stopped:    wrk.Stopped(),
+			Goroutines: goroutines,
+		}
+		for _, g := range goroutines {
+			if g.Busy {
+				workers.Busy++
+			}
+		}
+
+		c.JSON(http.StatusOK, StateDTO{
+			Time:    now,
+			Config:  sch.Config(),
+			Queue:   queueState,
+			Batcher: pending,
+			Nodes:   nodeDTOs,
+			Workers: workers,
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/sudo-JP/Load-Manager/common/tracing"
+)
+
+// Tracing starts the root span of a request, jobs created by the
+// handler carry it through the batcher, queue and worker
+func Tracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, span := tracing.Start(c.Request.Context(), "http.receive",
+			tracing.WithAttr("http.method", c.Request.Method),
+			tracing.WithAttr("http.route", c.FullPath()))
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		span.SetAttr("http.status_code", c.Writer.Status())
+		span.End()
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sudo-JP/Load-Manager/common/tracing"
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/batcher"
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
 )
@@ -35,8 +36,9 @@ func CreateUser(batch *batcher.Batcher) gin.HandlerFunc {
 			Resource:  queue.User,
 			CRUD:      queue.Create,
 			Payload:   payload,
-			Priority:  0,
+			Priority:  priority(c),
 			CreatedAt: time.Now(),
+			Trace:     tracing.SpanFromContext(c.Request.Context()).Context(),
 		}
 
 		batch.AddUser(job)
@@ -66,8 +68,9 @@ func GetUser(batch *batcher.Batcher) gin.HandlerFunc {
 			Resource:  queue.User,
 			CRUD:      queue.Read,
 			Payload:   payload,
-			Priority:  0,
+			Priority:  priority(c),
 			CreatedAt: time.Now(),
+			Trace:     tracing.SpanFromContext(c.Request.Context()).Context(),
 		}
 
 		batch.AddUser(job)
@@ -108,8 +111,9 @@ func UpdateUser(batch *batcher.Batcher) gin.HandlerFunc {
 			Resource:  queue.User,
 			CRUD:      queue.Update,
 			Payload:   payload,
-			Priority:  0,
+			Priority:  priority(c),
 			CreatedAt: time.Now(),
+			Trace:     tracing.SpanFromContext(c.Request.Context()).Context(),
 		}
 
 		batch.AddUser(job)
@@ -142,8 +146,9 @@ func DeleteUser(batch *batcher.Batcher) gin.HandlerFunc {
 			Resource:  queue.User,
 			CRUD:      queue.Delete,
 			Payload:   payload,
-			Priority:  0,
+			Priority:  priority(c),
 			CreatedAt: time.Now(),
+			Trace:     tracing.SpanFromContext(c.Request.Context()).Context(),
 		}
 
 		batch.AddUser(job)
@@ -0,0 +1,95 @@
+package scheduler
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue/algorithms"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/selector"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/strategy"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/worker"
+)
+
+// Algorithm names as given on the CLI, --queue, --selector and --load
+type Config struct {
+	Queue    string `json:"queue"`
+	Selector string `json:"selector"`
+	Strategy string `json:"strategy"`
+}
+
+/*
+Scheduler owns the queue algorithm, selector and strategy in use and
+switches them at runtime without a restart
+*/
+type Scheduler struct {
+	mutex  sync.Mutex
+	conf   Config
+	queue  *queue.Instrumented
+	worker *worker.Worker
+}
+
+func (s *Scheduler) Config() Config {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.conf
+}
+
+/*
+Apply switches to the non empty fields of update. Every name is checked
+before anything changes, so an invalid update leaves the scheduler as is.
+Waiting jobs are moved to the new queue
+*/
+func (s *Scheduler) Apply(update Config) (Config, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var (
+		q     queue.Queue
+		sel   selector.Selector
+		strat strategy.Strategy
+		err   error
+	)
+	swapStrategy := update.Strategy != "" && update.Strategy != s.conf.Strategy
+	if update.Queue != "" && update.Queue != s.conf.Queue {
+		if q, err = algorithms.NewQueue(update.Queue); err != nil {
+			return s.conf, err
+		}
+	}
+	if update.Selector != "" && update.Selector != s.conf.Selector {
+		if sel, err = selector.NewSelector(update.Selector); err != nil {
+			return s.conf, err
+		}
+	}
+	if swapStrategy {
+		if strat, err = strategy.NewStrategy(update.Strategy); err != nil {
+			return s.conf, err
+		}
+	}
+
+	if q != nil {
+		moved := s.queue.Swap(q, update.Queue)
+		slog.Info("Switched queue", "from", s.conf.Queue, "to", update.Queue, "moved", moved)
+		s.conf.Queue = update.Queue
+	}
+	if sel != nil {
+		s.worker.SetSelector(sel)
+		slog.Info("Switched selector", "from", s.conf.Selector, "to", update.Selector)
+		s.conf.Selector = update.Selector
+	}
+	if swapStrategy {
+		s.worker.SetStrategy(strat)
+		slog.Info("Switched strategy", "from", s.conf.Strategy, "to", update.Strategy)
+		s.conf.Strategy = update.Strategy
+	}
+
+	return s.conf, nil
+}
+
+func NewScheduler(conf Config, q *queue.Instrumented, wrk *worker.Worker) *Scheduler {
+	return &Scheduler{
+		conf:   conf,
+		queue:  q,
+		worker: wrk,
+	}
+}
@@ -18,9 +18,25 @@ func (r *Rand) SelectNode(nodes []*registry.BackendNode) *registry.BackendNode {
 		return nil 
 	}
 
-	node := nodes[rand.IntN(len(nodes))]
+	// Slow starting nodes are picked in proportion to their ramp
+	now := clock()
+	total := 0.0
+	for _, node := range nodes {
+		total += node.Ramp(now)
+	}
+	if total <= 0 {
+		return nodes[rand.IntN(len(nodes))]
+	}
+
+	pick := rand.Float64() * total
+	for _, node := range nodes {
+		pick -= node.Ramp(now)
+		if pick < 0 {
+			return node
+		}
+	}
 	
-	return node
+	return nodes[len(nodes) - 1]
 }
 
 func NewRand () Selector {
@@ -8,6 +8,7 @@ import (
 
 type RoundRobin struct {
 	next int 
+	credit map[int]float64 // node id -> share earned while slow starting
 	mutex sync.Mutex
 }
 
@@ -19,14 +20,41 @@ func (rr *RoundRobin) SelectNode(nodes []*registry.BackendNode) *registry.Backen
 		return nil 
 	}
 
-	node := nodes[rr.next % len(nodes)]
-	rr.next++
-	
-	return node
+	// Forget removed nodes
+	if len(rr.credit) > 2 * len(nodes) {
+		rr.credit = make(map[int]float64)
+	}
+
+	// A slow starting node earns its ramp each time its turn comes
+	// and only gets picked once it has a full share
+	now := clock()
+	var fallback *registry.BackendNode
+	for range nodes {
+		node := nodes[rr.next % len(nodes)]
+		rr.next++
+
+		ramp := node.Ramp(now)
+		if ramp >= 1 {
+			return node
+		}
+
+		rr.credit[node.ID] += ramp
+		if rr.credit[node.ID] >= 1 {
+			rr.credit[node.ID]--
+			return node
+		}
+		if fallback == nil || rr.credit[node.ID] > rr.credit[fallback.ID] {
+			fallback = node
+		}
+	}
+
+	// Every node is slow starting
+	return fallback
 }
 
 func NewRR () Selector {
 	return &RoundRobin{
 		next: 0, 
+		credit: make(map[int]float64),
 	}
 }
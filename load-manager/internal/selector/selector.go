@@ -1,9 +1,28 @@
 package selector
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/registry"
 )
 
+// Ramps are read against clock, tests move it forward
+var clock = time.Now
+
 type Selector interface {
 	SelectNode(nodes []*registry.BackendNode) *registry.BackendNode
 }
+
+// NewSelector builds a selector from its CLI name
+func NewSelector(name string) (Selector, error) {
+	switch name {
+	case "RR":
+		return NewRR(), nil
+	case "RAND":
+		return NewRand(), nil
+	case "WRR":
+		return NewWRR(), nil
+	}
+	return nil, fmt.Errorf("invalid selector %s. Must be: RR, RAND, WRR", name)
+}
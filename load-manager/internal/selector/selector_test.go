@@ -0,0 +1,99 @@
+package selector
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/registry"
+)
+
+// One node at full share and one a quarter into its slow start
+func rampingNodes(t *testing.T) (full, ramping *registry.BackendNode, nodes []*registry.BackendNode) {
+	reg := registry.NewRegistry()
+	full = reg.Add("localhost", 50001)
+	reg.SetSlowStart(time.Hour)
+	ramping = reg.Add("localhost", 50002)
+
+	start := time.Now()
+	clock = func() time.Time { return start.Add(15 * time.Minute) }
+	t.Cleanup(func() { clock = time.Now })
+	return full, ramping, reg.Available()
+}
+
+func TestSelectNode_RampShare(t *testing.T) {
+	const picks = 10000
+	for _, name := range []string{"RR", "RAND", "WRR"} {
+		t.Run(name, func(t *testing.T) {
+			_, ramping, nodes := rampingNodes(t)
+			sel, _ := NewSelector(name)
+
+			got := 0
+			for range picks {
+				if sel.SelectNode(nodes) == ramping {
+					got++
+				}
+			}
+			// A ramp of 0.25 next to a full node is 0.25 / 1.25 of the picks
+			share := float64(got) / picks
+			if math.Abs(share-0.2) > 0.02 {
+				t.Errorf("Expected the ramping node to get 20%% of picks, got %.1f%%", share*100)
+			}
+		})
+	}
+}
+
+func TestSelectNode_RampDone(t *testing.T) {
+	for _, name := range []string{"RR", "RAND", "WRR"} {
+		t.Run(name, func(t *testing.T) {
+			_, ramping, nodes := rampingNodes(t)
+			start := time.Now()
+			clock = func() time.Time { return start.Add(time.Hour) }
+			sel, _ := NewSelector(name)
+
+			got := 0
+			for range 1000 {
+				if sel.SelectNode(nodes) == ramping {
+					got++
+				}
+			}
+			if got < 400 || got > 600 {
+				t.Errorf("Expected an even split once the ramp is done, got %d of 1000", got)
+			}
+		})
+	}
+}
+
+func TestSelectNode_Empty(t *testing.T) {
+	for _, name := range []string{"RR", "RAND", "WRR"} {
+		sel, _ := NewSelector(name)
+		if node := sel.SelectNode(nil); node != nil {
+			t.Errorf("%s: expected no node, got %s", name, node.Addr())
+		}
+	}
+}
+
+func TestSelectNode_AllRampStart(t *testing.T) {
+	for _, name := range []string{"RR", "RAND", "WRR"} {
+		t.Run(name, func(t *testing.T) {
+			reg := registry.NewRegistry()
+			reg.SetSlowStart(time.Hour)
+			reg.Add("localhost", 50001)
+			reg.Add("localhost", 50002)
+			nodes := reg.Available()
+			start := time.Now()
+			clock = func() time.Time { return start.Add(-time.Minute) }
+			t.Cleanup(func() { clock = time.Now })
+
+			for _, node := range nodes {
+				if ramp := node.Ramp(clock()); ramp != 0 {
+					t.Fatalf("Expected no ramp yet, got %v", ramp)
+				}
+			}
+			sel, _ := NewSelector(name)
+			if sel.SelectNode(nodes) == nil {
+				t.Errorf("Expected a pick while every node is slow starting")
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package selector
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/registry"
+)
+
+// Weights are scaled so slow start can shrink them smoothly
+const weightScale = 1000
+
+/*
+Smooth weighted round robin (same as nginx), a node with weight 3 next to
+a node with weight 1 gets picked A A B A instead of A A A B
+*/
+type WeightedRR struct {
+	current map[int]int // node id -> current weight
+	mutex   sync.Mutex
+}
+
+func (w *WeightedRR) SelectNode(nodes []*registry.BackendNode) *registry.BackendNode {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	// Forget removed nodes
+	if len(w.current) > 2*len(nodes) {
+		w.current = make(map[int]int)
+	}
+
+	now := clock()
+	total := 0
+	var best *registry.BackendNode
+	for _, node := range nodes {
+		full := atomic.LoadInt32(&node.Weight) * weightScale
+		if full <= 0 {
+			continue
+		}
+		// A node at the very start of its ramp still gets a sliver,
+		// otherwise a registry that is all slow starting has no node to pick
+		weight := max(int(float64(full)*node.Ramp(now)), 1)
+
+		w.current[node.ID] += weight
+		total += weight
+		if best == nil || w.current[node.ID] > w.current[best.ID] {
+			best = node
+		}
+	}
+
+	if best == nil {
+		return nil
+	}
+	w.current[best.ID] -= total
+
+	return best
+}
+
+func NewWRR() Selector {
+	return &WeightedRR{
+		current: make(map[int]int),
+	}
+}
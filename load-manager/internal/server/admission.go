@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/admission"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type maxPriorityKey struct{}
+
+// Highest priority the caller may ask for, 0 unless set by
+// PriorityInterceptor or AuthInterceptor
+func maxPriority(ctx context.Context) int {
+	p, _ := ctx.Value(maxPriorityKey{}).(int)
+	return p
+}
+
+// PriorityInterceptor caps x-priority at max for calls without an API key,
+// it goes before AuthInterceptor
+func PriorityInterceptor(max int) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (any, error) {
+		return handler(context.WithValue(ctx, maxPriorityKey{}, max), req)
+	}
+}
+
+// priorityOf reads x-priority metadata, like the HTTP X-Priority header,
+// capped the same way
+func priorityOf(ctx context.Context) (int, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	v := md.Get("x-priority")
+	if len(v) == 0 {
+		return 0, nil
+	}
+	p, err := strconv.Atoi(v[0])
+	if err != nil {
+		return 0, status.Errorf(codes.InvalidArgument, "invalid x-priority %s", v[0])
+	}
+	return min(p, maxPriority(ctx)), nil
+}
+
+// AdmissionInterceptor answers Unavailable while the queue is shedding load
+func AdmissionInterceptor(ctrl *admission.Controller) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (any, error) {
+		p, err := priorityOf(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !ctrl.Admit(p) {
+			return nil, status.Error(codes.Unavailable, "overloaded, retry later")
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// apiKey reads "authorization: Bearer <key>" or "x-api-key: <key>" metadata
+func apiKey(md metadata.MD) string {
+	for _, v := range md.Get("authorization") {
+		if bearer, ok := strings.CutPrefix(v, "Bearer "); ok {
+			return strings.TrimSpace(bearer)
+		}
+	}
+	if v := md.Get("x-api-key"); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// "/user.UserService/CreateUsers" is a user write, Get* methods are reads
+func methodScope(fullMethod string) (resource string, write bool) {
+	service, method, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	resource, _, _ = strings.Cut(service, ".")
+	return resource, !strings.HasPrefix(method, "Get")
+}
+
+// AuthInterceptor applies the HTTP API key rules to the front door
+func AuthInterceptor(keys *auth.Keys) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		resource, write := methodScope(info.FullMethod)
+
+		id, err := keys.Authorize(apiKey(md), resource, write)
+		if err != nil {
+			code := codes.Unauthenticated
+			if errors.Is(err, auth.ErrForbidden) {
+				code = codes.PermissionDenied
+			}
+			var client string
+			if p, ok := peer.FromContext(ctx); ok {
+				client = p.Addr.String()
+			}
+			slog.Warn("Rejected request", "key_id", id, "method", info.FullMethod,
+				"client", client, "reason", err)
+			return nil, status.Error(code, err.Error())
+		}
+		ctx = context.WithValue(ctx, keyIDKey{}, id)
+		return handler(context.WithValue(ctx, maxPriorityKey{}, keys.MaxPriority(id)), req)
+	}
+}
+
+type keyIDKey struct{}
+
+// keyID is the API key that authorized the call, empty without auth
+func keyID(ctx context.Context) string {
+	id, _ := ctx.Value(keyIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+
+	pb "github.com/sudo-JP/Load-Manager/load-manager/api/proto/order"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/batcher"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/routes"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// All GetOrdersRequest filters, the HTTP route only sends order_id
+type getOrdersPayload struct {
+	UserID    int64  `json:"user_id"`
+	Page      int32  `json:"page"`
+	OrderID   *int64 `json:"order_id,omitempty"`
+	ProductID *int64 `json:"product_id,omitempty"`
+}
+
+type OrderServer struct {
+	pb.UnimplementedOrderServiceServer
+	batch *batcher.Batcher
+}
+
+func (s *OrderServer) CreateOrders(ctx context.Context,
+	req *pb.CreateOrdersRequest) (*emptypb.Empty, error) {
+	jobs := make([]*queue.Job, 0, len(req.Orders))
+	for i, order := range req.Orders {
+		job, err := newJob(ctx, queue.Order, queue.Create, i, routes.CreateOrderDTO{
+			UserID:    int(order.UserId),
+			ProductID: int(order.ProductId),
+			Quantity:  int(order.Quantity),
+		})
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	if _, err := submit(ctx, jobs, s.batch.AddOrder); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *OrderServer) GetOrders(ctx context.Context,
+	req *pb.GetOrdersRequest) (*pb.GetOrdersResponse, error) {
+	job, err := newJob(ctx, queue.Order, queue.Read, 0, getOrdersPayload{
+		UserID:    req.UserId,
+		Page:      req.Page,
+		OrderID:   req.OrderId,
+		ProductID: req.ProductId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := submit(ctx, []*queue.Job{job}, s.batch.AddOrder)
+	if err != nil {
+		return nil, err
+	}
+	return response[*pb.GetOrdersResponse](results)
+}
+
+func (s *OrderServer) UpdateOrders(ctx context.Context,
+	req *pb.UpdateOrdersRequest) (*emptypb.Empty, error) {
+	jobs := make([]*queue.Job, 0, len(req.Orders))
+	for i, order := range req.Orders {
+		job, err := newJob(ctx, queue.Order, queue.Update, i, routes.UpdateOrderDTO{
+			OrderID:  int(order.OrderId),
+			Quantity: int(order.Quantity),
+		})
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	if _, err := submit(ctx, jobs, s.batch.AddOrder); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *OrderServer) DeleteOrders(ctx context.Context,
+	req *pb.DeleteOrdersRequest) (*emptypb.Empty, error) {
+	jobs := make([]*queue.Job, 0, len(req.OrderIds))
+	for i, id := range req.OrderIds {
+		job, err := newJob(ctx, queue.Order, queue.Delete, i, routes.DeleteOrderDTO{OrderID: int(id)})
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	if _, err := submit(ctx, jobs, s.batch.AddOrder); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func NewOrderServer(batch *batcher.Batcher) *OrderServer {
+	return &OrderServer{batch: batch}
+}
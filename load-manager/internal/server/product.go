@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+
+	pb "github.com/sudo-JP/Load-Manager/load-manager/api/proto/product"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/batcher"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/routes"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// product_id < 0 returns all, so no binding rule unlike the HTTP route
+type getProductsPayload struct {
+	ProductID int64 `json:"product_id"`
+}
+
+type ProductServer struct {
+	pb.UnimplementedProductServiceServer
+	batch *batcher.Batcher
+}
+
+func (s *ProductServer) CreateProducts(ctx context.Context,
+	req *pb.CreateProductsRequest) (*emptypb.Empty, error) {
+	jobs := make([]*queue.Job, 0, len(req.Products))
+	for i, product := range req.Products {
+		job, err := newJob(ctx, queue.Product, queue.Create, i, routes.CreateProductDTO{
+			Name:    product.Name,
+			Version: product.Version,
+		})
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	if _, err := submit(ctx, jobs, s.batch.AddProduct); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *ProductServer) GetProducts(ctx context.Context,
+	req *pb.GetProductsRequest) (*pb.GetProductsResponse, error) {
+	job, err := newJob(ctx, queue.Product, queue.Read, 0, getProductsPayload{ProductID: req.ProductId})
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := submit(ctx, []*queue.Job{job}, s.batch.AddProduct)
+	if err != nil {
+		return nil, err
+	}
+	return response[*pb.GetProductsResponse](results)
+}
+
+func (s *ProductServer) UpdateProducts(ctx context.Context,
+	req *pb.UpdateProductsRequest) (*emptypb.Empty, error) {
+	jobs := make([]*queue.Job, 0, len(req.Products))
+	for i, product := range req.Products {
+		job, err := newJob(ctx, queue.Product, queue.Update, i, routes.UpdateProductDTO{
+			ProductID: int(product.ProductId),
+			Name:      product.Name,
+			Version:   product.Version,
+		})
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	if _, err := submit(ctx, jobs, s.batch.AddProduct); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *ProductServer) DeleteProducts(ctx context.Context,
+	req *pb.DeleteProductsRequest) (*emptypb.Empty, error) {
+	jobs := make([]*queue.Job, 0, len(req.ProductIds))
+	for i, id := range req.ProductIds {
+		job, err := newJob(ctx, queue.Product, queue.Delete, i, routes.DeleteProductDTO{ProductID: int(id)})
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	if _, err := submit(ctx, jobs, s.batch.AddProduct); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func NewProductServer(batch *batcher.Batcher) *ProductServer {
+	return &ProductServer{batch: batch}
+}
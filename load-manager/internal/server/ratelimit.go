@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+
+	pbOrder "github.com/sudo-JP/Load-Manager/load-manager/api/proto/order"
+	pbProduct "github.com/sudo-JP/Load-Manager/load-manager/api/proto/product"
+	pbUser "github.com/sudo-JP/Load-Manager/load-manager/api/proto/user"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// HTTP method of the matching route, so rules apply to both doors
+func httpMethod(fullMethod string) string {
+	_, method, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	switch {
+	case strings.HasPrefix(method, "Get"):
+		return "GET"
+	case strings.HasPrefix(method, "Create"):
+		return "POST"
+	case strings.HasPrefix(method, "Update"):
+		return "PUT"
+	case strings.HasPrefix(method, "Delete"):
+		return "DELETE"
+	}
+	return method
+}
+
+// Items in a request, every one costs a token like an HTTP bulk item
+func itemCount(req any) int {
+	switch r := req.(type) {
+	case interface{ GetUsers() []*pbUser.User }:
+		return len(r.GetUsers())
+	case interface{ GetProducts() []*pbProduct.Product }:
+		return len(r.GetProducts())
+	case interface{ GetProductIds() []int64 }:
+		return len(r.GetProductIds())
+	case interface{ GetOrders() []*pbOrder.Order }:
+		return len(r.GetOrders())
+	case interface{ GetOrderIds() []int64 }:
+		return len(r.GetOrderIds())
+	}
+	return 1
+}
+
+// RateLimitInterceptor answers ResourceExhausted like the HTTP 429, with
+// the quota in x-ratelimit-* trailers. A call takes a token per item
+func RateLimitInterceptor(limiter *ratelimit.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (any, error) {
+		client := ""
+		if id := keyID(ctx); id != "" {
+			client = "key:" + id
+		} else if p, ok := peer.FromContext(ctx); ok {
+			host, _, _ := net.SplitHostPort(p.Addr.String())
+			client = "ip:" + host
+		}
+
+		resource, _ := methodScope(info.FullMethod)
+		d := limiter.AllowN(client, httpMethod(info.FullMethod), resource, itemCount(req))
+		if d.Limit == 0 {
+			return handler(ctx, req)
+		}
+
+		md := metadata.Pairs(
+			"x-ratelimit-limit", strconv.Itoa(d.Limit),
+			"x-ratelimit-remaining", strconv.Itoa(d.Remaining))
+		if !d.Allowed {
+			md.Set("retry-after", strconv.Itoa(int(math.Ceil(d.RetryAfter.Seconds()))))
+			_ = grpc.SetTrailer(ctx, md)
+			return nil, status.Error(codes.ResourceExhausted, "rate limit exceeded")
+		}
+		_ = grpc.SetTrailer(ctx, md)
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/sudo-JP/Load-Manager/common/tracing"
+	pbOrder "github.com/sudo-JP/Load-Manager/load-manager/api/proto/order"
+	pbProduct "github.com/sudo-JP/Load-Manager/load-manager/api/proto/product"
+	pbUser "github.com/sudo-JP/Load-Manager/load-manager/api/proto/user"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/admission"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/batcher"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/breaker"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/concurrency"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/worker"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*
+gRPC front door: the same services the backends expose, served by the load
+manager. Every item of a request becomes a job that is batched and scheduled
+like an HTTP request, the RPC returns once the backend answered for all of them
+*/
+
+// newJob checks dto against its binding tags, the same rules as the HTTP routes
+func newJob(ctx context.Context, resource queue.JobType, crud queue.Operation,
+	idx int, dto any) (*queue.Job, error) {
+	if err := binding.Validator.ValidateStruct(dto); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "item %d: %v", idx, err)
+	}
+
+	payload, err := json.Marshal(dto)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "item %d: %v", idx, err)
+	}
+	priority, err := priorityOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &queue.Job{
+		ID:        queue.GetID(),
+		Resource:  resource,
+		CRUD:      crud,
+		Payload:   payload,
+		Priority:  priority,
+		CreatedAt: time.Now(),
+		Trace:     tracing.SpanFromContext(ctx).Context(),
+	}
+	job.NewReply()
+	return job, nil
+}
+
+// submit hands the jobs to add and waits for every outcome, the first error wins
+func submit(ctx context.Context, jobs []*queue.Job, add func(*queue.Job)) ([]queue.Result, error) {
+	if len(jobs) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "empty request")
+	}
+
+	for _, job := range jobs {
+		add(job)
+	}
+
+	results := make([]queue.Result, len(jobs))
+	for i, job := range jobs {
+		select {
+		case results[i] = <-job.Reply:
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+	}
+
+	for _, result := range results {
+		if result.Err != nil {
+			return nil, toStatus(result.Err)
+		}
+	}
+	return results, nil
+}
+
+// Backend errors already carry a status, scheduling errors are mapped
+func toStatus(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch {
+	case errors.Is(err, worker.ErrNoNode), errors.Is(err, breaker.ErrOpen),
+		errors.Is(err, admission.ErrShed), errors.Is(err, concurrency.ErrLimited):
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// Reads return the backend response of their single job
+func response[T any](results []queue.Result) (T, error) {
+	resp, ok := results[0].Response.(T)
+	if !ok {
+		var zero T
+		return zero, status.Error(codes.Internal, fmt.Sprintf("unexpected response %T", results[0].Response))
+	}
+	return resp, nil
+}
+
+// NewGRPCServer serves all three services, traced like the HTTP routes.
+// opts can add credentials and more interceptors, chained after tracing
+func NewGRPCServer(batch *batcher.Batcher, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append([]grpc.ServerOption{grpc.ChainUnaryInterceptor(tracing.UnaryServerInterceptor())}, opts...)
+	srv := grpc.NewServer(opts...)
+	pbUser.RegisterUserServiceServer(srv, NewUserServer(batch))
+	pbProduct.RegisterProductServiceServer(srv, NewProductServer(batch))
+	pbOrder.RegisterOrderServiceServer(srv, NewOrderServer(batch))
+	return srv
+}
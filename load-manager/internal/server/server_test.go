@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	pbOrder "github.com/sudo-JP/Load-Manager/load-manager/api/proto/order"
+	pbProduct "github.com/sudo-JP/Load-Manager/load-manager/api/proto/product"
+	pb "github.com/sudo-JP/Load-Manager/load-manager/api/proto/user"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/admission"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/batcher"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/breaker"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/concurrency"
+	lmgrpc "github.com/sudo-JP/Load-Manager/load-manager/internal/grpc"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue/algorithms"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/registry"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/selector"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/strategy"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/worker"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestToStatus(t *testing.T) {
+	cases := []struct {
+		err  error
+		code codes.Code
+	}{
+		{status.Error(codes.NotFound, "no such user"), codes.NotFound},
+		{worker.ErrNoNode, codes.Unavailable},
+		{fmt.Errorf("node localhost:50001: %w", breaker.ErrOpen), codes.Unavailable},
+		{admission.ErrShed, codes.Unavailable},
+		{fmt.Errorf("node localhost:50001: %w", concurrency.ErrLimited), codes.Unavailable},
+		{fmt.Errorf("node localhost:50001 was removed"), codes.Internal},
+	}
+
+	for _, c := range cases {
+		if got := status.Code(toStatus(c.err)); got != c.code {
+			t.Errorf("toStatus(%v) = %v, expected %v", c.err, got, c.code)
+		}
+	}
+}
+
+// Backend stub, every user exists and no user can be created
+type stubUsers struct {
+	pb.UnimplementedUserServiceServer
+}
+
+func (stubUsers) GetUsers(ctx context.Context, req *pb.GetUsersRequest) (*pb.GetUsersResponse, error) {
+	return &pb.GetUsersResponse{Users: []*pb.User{{UserId: 1, Email: req.Email}}}, nil
+}
+
+func (stubUsers) CreateUsers(ctx context.Context, req *pb.CreateUsersRequest) (*emptypb.Empty, error) {
+	return nil, status.Error(codes.AlreadyExists, "email taken")
+}
+
+func serve(t *testing.T, register func(*grpc.Server)) *net.TCPAddr {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	register(srv)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis.Addr().(*net.TCPAddr)
+}
+
+// The front door, batcher, queue and worker in process, in front of the stub
+func frontDoor(t *testing.T) pb.UserServiceClient {
+	t.Helper()
+	backend := serve(t, func(srv *grpc.Server) { pb.RegisterUserServiceServer(srv, stubUsers{}) })
+	reg := registry.NewRegistry()
+	reg.Add("127.0.0.1", backend.Port)
+
+	q := algorithms.NewFCFSQueue()
+	bat := batcher.NewBatcher(q, 10, time.Millisecond)
+	t.Cleanup(bat.Stop)
+	sel, _ := selector.NewSelector("RR")
+	wrk := worker.NewWorker(q, reg, sel, map[int]*lmgrpc.BackendClient{}, 1, strategy.Mixed{})
+	t.Cleanup(wrk.Stop)
+
+	addr := serve(t, func(srv *grpc.Server) {
+		pb.RegisterUserServiceServer(srv, NewUserServer(bat))
+	})
+	conn, err := grpc.NewClient(addr.String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewUserServiceClient(conn)
+}
+
+func TestFrontDoor(t *testing.T) {
+	client := frontDoor(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := client.GetUsers(ctx, &pb.GetUsersRequest{Email: "a@example.com"})
+	if err != nil {
+		t.Fatalf("Expected the backend's answer, got %v", err)
+	}
+	if len(resp.Users) != 1 || resp.Users[0].Email != "a@example.com" {
+		t.Errorf("Expected the stub's user, got %v", resp.Users)
+	}
+
+	_, err = client.CreateUsers(ctx, &pb.CreateUsersRequest{Users: []*pb.User{
+		{Name: "a", Email: "a@example.com", Password: "password"},
+	}})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Errorf("Expected the backend's AlreadyExists passed through, got %v", err)
+	}
+
+	// Invalid items never reach the backend
+	_, err = client.CreateUsers(ctx, &pb.CreateUsersRequest{Users: []*pb.User{{Name: "a"}}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestPriorityOf_Capped(t *testing.T) {
+	tests := []struct {
+		header string
+		max    int
+		want   int
+	}{
+		{"", 5, 0},
+		{"3", 5, 3},
+		{"9", 5, 5},
+		{"-1", 0, -1},
+	}
+	for _, tt := range tests {
+		ctx := context.WithValue(context.Background(), maxPriorityKey{}, tt.max)
+		if tt.header != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("x-priority", tt.header))
+		}
+		if got, _ := priorityOf(ctx); got != tt.want {
+			t.Errorf("x-priority %q with max %d: expected %d, got %d", tt.header, tt.max, tt.want, got)
+		}
+	}
+
+	// Without PriorityInterceptor or a key nothing goes above 0
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-priority", "9"))
+	if got, _ := priorityOf(ctx); got != 0 {
+		t.Errorf("Expected an uncapped call held at 0, got %d", got)
+	}
+}
+
+func TestItemCount(t *testing.T) {
+	tests := []struct {
+		req  any
+		want int
+	}{
+		{&pb.CreateUsersRequest{Users: []*pb.User{{}, {}, {}}}, 3},
+		{&pbProduct.DeleteProductsRequest{ProductIds: []int64{1, 2}}, 2},
+		{&pbOrder.UpdateOrdersRequest{Orders: []*pbOrder.Order{{}}}, 1},
+		{&pb.GetUsersRequest{Email: "a@example.com"}, 1},
+	}
+	for _, tt := range tests {
+		if got := itemCount(tt.req); got != tt.want {
+			t.Errorf("%T: expected %d items, got %d", tt.req, tt.want, got)
+		}
+	}
+}
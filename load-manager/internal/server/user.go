@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+
+	pb "github.com/sudo-JP/Load-Manager/load-manager/api/proto/user"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/batcher"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/routes"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type UserServer struct {
+	pb.UnimplementedUserServiceServer
+	batch *batcher.Batcher
+}
+
+func (s *UserServer) CreateUsers(ctx context.Context,
+	req *pb.CreateUsersRequest) (*emptypb.Empty, error) {
+	jobs := make([]*queue.Job, 0, len(req.Users))
+	for i, user := range req.Users {
+		job, err := newJob(ctx, queue.User, queue.Create, i, routes.CreateUserDTO{
+			Name:     user.Name,
+			Email:    user.Email,
+			Password: user.Password,
+		})
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	if _, err := submit(ctx, jobs, s.batch.AddUser); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *UserServer) GetUsers(ctx context.Context,
+	req *pb.GetUsersRequest) (*pb.GetUsersResponse, error) {
+	job, err := newJob(ctx, queue.User, queue.Read, 0, routes.GetUserDTO{Email: req.Email})
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := submit(ctx, []*queue.Job{job}, s.batch.AddUser)
+	if err != nil {
+		return nil, err
+	}
+	return response[*pb.GetUsersResponse](results)
+}
+
+func (s *UserServer) UpdateUsers(ctx context.Context,
+	req *pb.UpdateUsersRequest) (*emptypb.Empty, error) {
+	jobs := make([]*queue.Job, 0, len(req.Users))
+	for i, user := range req.Users {
+		job, err := newJob(ctx, queue.User, queue.Update, i, routes.UpdateUserDTO{
+			Email:    user.Email,
+			Name:     user.Name,
+			Password: user.Password,
+		})
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	if _, err := submit(ctx, jobs, s.batch.AddUser); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *UserServer) DeleteUsers(ctx context.Context,
+	req *pb.DeleteUsersRequest) (*emptypb.Empty, error) {
+	jobs := make([]*queue.Job, 0, len(req.Users))
+	for i, user := range req.Users {
+		job, err := newJob(ctx, queue.User, queue.Delete, i, routes.DeleteUserDTO{Email: user.Email})
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	if _, err := submit(ctx, jobs, s.batch.AddUser); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func NewUserServer(batch *batcher.Batcher) *UserServer {
+	return &UserServer{batch: batch}
+}
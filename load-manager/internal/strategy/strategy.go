@@ -0,0 +1,151 @@
+package strategy
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/registry"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/selector"
+)
+
+// Jobs of one resource and operation sent to Node in one call, no node
+// could be selected when Node is nil
+type Assignment struct {
+	Node     *registry.BackendNode
+	Resource queue.JobType
+	CRUD     queue.Operation
+	Jobs     []*queue.Job
+}
+
+/*
+Strategy splits a batch of popped jobs across nodes. nodes are the ones
+available right now and sel picks among them. Every job goes into exactly
+one assignment, the worker sends them in order
+*/
+type Strategy interface {
+	Assign(jobs []*queue.Job, nodes []*registry.BackendNode, sel selector.Selector) []Assignment
+}
+
+var (
+	mutex     sync.RWMutex
+	factories = make(map[string]func() Strategy)
+	names     []string // in registration order, for errors and help
+)
+
+// Register makes a strategy available by name, it panics if the name is taken
+func Register(name string, factory func() Strategy) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if _, ok := factories[name]; ok {
+		panic("strategy: " + name + " registered twice")
+	}
+	factories[name] = factory
+	names = append(names, name)
+}
+
+// Names of the registered strategies
+func Names() []string {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return append([]string(nil), names...)
+}
+
+// NewStrategy builds a strategy from its CLI name
+func NewStrategy(name string) (Strategy, error) {
+	mutex.RLock()
+	factory, ok := factories[name]
+	mutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("invalid load strat %s. Must be: %s", name, strings.Join(Names(), ", "))
+	}
+	return factory(), nil
+}
+
+func init() {
+	Register("M", func() Strategy { return Mixed{} })
+	Register("PR", func() Strategy { return PerResource{} })
+	Register("PO", func() Strategy { return PerOperation{} })
+	Register("PRO", func() Strategy { return PerResourceAndOperation{} })
+}
+
+// group splits jobs by key in order of first appearance, nil jobs are skipped
+func group[K comparable](jobs []*queue.Job, key func(*queue.Job) K) [][]*queue.Job {
+	index := make(map[K]int)
+	var groups [][]*queue.Job
+	for _, job := range jobs {
+		if job == nil {
+			continue
+		}
+		k := key(job)
+		if i, ok := index[k]; ok {
+			groups[i] = append(groups[i], job)
+			continue
+		}
+		index[k] = len(groups)
+		groups = append(groups, []*queue.Job{job})
+	}
+	return groups
+}
+
+func byResource(job *queue.Job) queue.JobType { return job.Resource }
+func byCRUD(job *queue.Job) queue.Operation   { return job.CRUD }
+
+// One call per resource and operation in jobs, all to node
+func split(node *registry.BackendNode, jobs []*queue.Job) []Assignment {
+	var out []Assignment
+	for _, resourceJobs := range group(jobs, byResource) {
+		for _, crudJobs := range group(resourceJobs, byCRUD) {
+			out = append(out, Assignment{
+				Node:     node,
+				Resource: crudJobs[0].Resource,
+				CRUD:     crudJobs[0].CRUD,
+				Jobs:     crudJobs,
+			})
+		}
+	}
+	return out
+}
+
+// Mixed sends the whole batch to one node
+type Mixed struct{}
+
+func (Mixed) Assign(jobs []*queue.Job, nodes []*registry.BackendNode, sel selector.Selector) []Assignment {
+	return split(sel.SelectNode(nodes), jobs)
+}
+
+// PerResource picks a node for each resource in the batch
+type PerResource struct{}
+
+func (PerResource) Assign(jobs []*queue.Job, nodes []*registry.BackendNode, sel selector.Selector) []Assignment {
+	var out []Assignment
+	for _, resourceJobs := range group(jobs, byResource) {
+		out = append(out, split(sel.SelectNode(nodes), resourceJobs)...)
+	}
+	return out
+}
+
+// PerOperation picks a node for each operation in the batch
+type PerOperation struct{}
+
+func (PerOperation) Assign(jobs []*queue.Job, nodes []*registry.BackendNode, sel selector.Selector) []Assignment {
+	var out []Assignment
+	for _, crudJobs := range group(jobs, byCRUD) {
+		out = append(out, split(sel.SelectNode(nodes), crudJobs)...)
+	}
+	return out
+}
+
+// PerResourceAndOperation picks a node for every call
+type PerResourceAndOperation struct{}
+
+func (PerResourceAndOperation) Assign(jobs []*queue.Job, nodes []*registry.BackendNode, sel selector.Selector) []Assignment {
+	var out []Assignment
+	for _, crudJobs := range group(jobs, byCRUD) {
+		for _, resourceJobs := range group(crudJobs, byResource) {
+			out = append(out, split(sel.SelectNode(nodes), resourceJobs)...)
+		}
+	}
+	return out
+}
@@ -0,0 +1,118 @@
+package strategy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/registry"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/selector"
+)
+
+func nodes(n int) []*registry.BackendNode {
+	reg := registry.NewRegistry()
+	for i := range n {
+		reg.Add("localhost", 50001+i)
+	}
+	return reg.Available()
+}
+
+// A batch with two resources and two operations
+func batch() []*queue.Job {
+	return []*queue.Job{
+		{ID: 0, Resource: queue.User, CRUD: queue.Create},
+		{ID: 1, Resource: queue.Order, CRUD: queue.Create},
+		{ID: 2, Resource: queue.User, CRUD: queue.Read},
+		{ID: 3, Resource: queue.User, CRUD: queue.Create},
+		nil,
+	}
+}
+
+func TestAssign(t *testing.T) {
+	tests := []struct {
+		name  string
+		strat Strategy
+		calls int // one per resource and operation
+		nodes int // distinct nodes used
+	}{
+		{"mixed", Mixed{}, 3, 1},
+		{"per resource", PerResource{}, 3, 2},
+		{"per operation", PerOperation{}, 3, 2},
+		{"per resource and operation", PerResourceAndOperation{}, 3, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assigned := tt.strat.Assign(batch(), nodes(3), selector.NewRR())
+			if len(assigned) != tt.calls {
+				t.Fatalf("Expected %d calls, got %d", tt.calls, len(assigned))
+			}
+			used := make(map[*registry.BackendNode]bool)
+			jobs := 0
+			for _, a := range assigned {
+				used[a.Node] = true
+				for _, job := range a.Jobs {
+					if job.Resource != a.Resource || job.CRUD != a.CRUD {
+						t.Errorf("Job %d in the %s %s call", job.ID, a.Resource, a.CRUD)
+					}
+				}
+				jobs += len(a.Jobs)
+			}
+			if len(used) != tt.nodes {
+				t.Errorf("Expected %d nodes, got %d", tt.nodes, len(used))
+			}
+			if jobs != 4 {
+				t.Errorf("Expected all 4 jobs assigned, got %d", jobs)
+			}
+		})
+	}
+}
+
+func TestAssign_KeepsOrder(t *testing.T) {
+	assigned := Mixed{}.Assign(batch(), nodes(1), selector.NewRR())
+	if ids := assigned[0].Jobs; ids[0].ID != 0 || ids[1].ID != 3 {
+		t.Errorf("Expected user creates 0 and 3 first, got %d and %d", ids[0].ID, ids[1].ID)
+	}
+	if assigned[1].Resource != queue.User || assigned[1].CRUD != queue.Read {
+		t.Errorf("Expected the user read second, got %s %s", assigned[1].Resource, assigned[1].CRUD)
+	}
+}
+
+func TestAssign_NoNode(t *testing.T) {
+	for _, name := range Names() {
+		strat, _ := NewStrategy(name)
+		for _, a := range strat.Assign(batch(), nil, selector.NewRR()) {
+			if a.Node != nil {
+				t.Errorf("%s: expected no node without nodes, got %s", name, a.Node.Addr())
+			}
+		}
+	}
+}
+
+type firstNode struct{}
+
+func (firstNode) Assign(jobs []*queue.Job, nodes []*registry.BackendNode, sel selector.Selector) []Assignment {
+	return split(nodes[0], jobs)
+}
+
+func TestRegister(t *testing.T) {
+	Register("FIRST", func() Strategy { return firstNode{} })
+	strat, err := NewStrategy("FIRST")
+	if err != nil {
+		t.Fatalf("Expected the registered strategy, got %v", err)
+	}
+	if _, ok := strat.(firstNode); !ok {
+		t.Errorf("Expected firstNode, got %T", strat)
+	}
+
+	_, err = NewStrategy("NOPE")
+	if err == nil || !strings.Contains(err.Error(), "M, PR, PO, PRO, FIRST") {
+		t.Errorf("Expected the error to list every strategy, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected registering a taken name to panic")
+		}
+	}()
+	Register("M", func() Strategy { return Mixed{} })
+}
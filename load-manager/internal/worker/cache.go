@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/cache"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/grpc"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/registry"
+)
+
+// SetCache caches read responses, nil turns caching off
+func (w *Worker) SetCache(c *cache.Cache) {
+	w.confMut.Lock()
+	defer w.confMut.Unlock()
+	w.cache = c
+}
+
+func (w *Worker) readCache() *cache.Cache {
+	w.confMut.RLock()
+	defer w.confMut.RUnlock()
+	return w.cache
+}
+
+// Writes drop the resource's cached reads when dispatched and again once
+// done, so reads racing the write are not kept either
+func (w *Worker) invalidate(resource queue.JobType) func() {
+	c := w.readCache()
+	if c == nil {
+		return func() {}
+	}
+	c.Invalidate(resource.String())
+	return func() { c.Invalidate(resource.String()) }
+}
+
+/*
+cachedRead answers a read job from the cache, keyed by resource and the
+job's payload, which holds the query. Misses are coalesced with identical
+reads in flight, successful answers are stored. Hits report node as the
+one that answered
+*/
+func cachedRead[T any](w *Worker, node *registry.BackendNode, method string, job *queue.Job,
+	fn func(ctx context.Context, client *grpc.BackendClient) (T, error)) (T, *registry.BackendNode, error) {
+	c := w.readCache()
+	if c == nil {
+		return coalescedRead(w, node, method, job, readKey(job), fn)
+	}
+
+	resource, query := job.Resource.String(), string(job.Payload)
+	if resp, ok := c.Get(resource, query); ok {
+		return resp.(T), node, nil
+	}
+	// Reads after a write must not join a flight from before it
+	generation := c.Generation(resource)
+	key := fmt.Sprintf("%s#%d", readKey(job), generation)
+	resp, answered, err := coalescedRead(w, node, method, job, key, fn)
+	if err == nil {
+		c.Put(resource, query, generation, resp)
+	}
+	return resp, answered, err
+}
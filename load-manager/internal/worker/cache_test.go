@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/sudo-JP/Load-Manager/load-manager/api/proto/order"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/cache"
+	lmgrpc "github.com/sudo-JP/Load-Manager/load-manager/internal/grpc"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+)
+
+func TestCachedRead_InvalidatedByWrite(t *testing.T) {
+	w, nodes, servers := newTestWorker(t, 0)
+	node, orders := nodes[0], servers[0]
+	w.SetCache(cache.NewCache(10, time.Minute))
+
+	read := func(payload string) {
+		job := &queue.Job{Resource: queue.Order, CRUD: queue.Read, Payload: []byte(payload)}
+		_, _, err := cachedRead(w, node, "GetOrders", job,
+			func(ctx context.Context, client *lmgrpc.BackendClient) (*pb.GetOrdersResponse, error) {
+				return client.Orders.GetOrders(ctx, &pb.GetOrdersRequest{})
+			})
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+	}
+
+	read(`{"order_id":1}`)
+	read(`{"order_id":1}`)
+	if n := orders.calls.Load(); n != 1 {
+		t.Errorf("Expected the second read from cache, backend saw %d", n)
+	}
+	read(`{"order_id":2}`)
+	if n := orders.calls.Load(); n != 2 {
+		t.Errorf("Expected another query to miss, backend saw %d", n)
+	}
+
+	w.invalidate(queue.Order)()
+	read(`{"order_id":1}`)
+	if n := orders.calls.Load(); n != 3 {
+		t.Errorf("Expected a write to invalidate the read, backend saw %d", n)
+	}
+}
@@ -0,0 +1,97 @@
+package worker
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/grpc"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/registry"
+)
+
+// One backend call in flight, shared by every identical read that joins it
+type flight struct {
+	done chan struct{}
+	resp any
+	node *registry.BackendNode
+	err  error
+}
+
+// flightGroup is a singleflight keyed by resource and query
+type flightGroup struct {
+	mutex sync.Mutex
+	calls map[string]*flight
+}
+
+// do runs fn unless a call for key is in flight, then it waits for that one.
+// shared is true for the callers that joined
+func (g *flightGroup) do(key string, fn func() (any, *registry.BackendNode, error)) (
+	resp any, node *registry.BackendNode, shared bool, err error) {
+	g.mutex.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flight)
+	}
+	if f, ok := g.calls[key]; ok {
+		g.mutex.Unlock()
+		<-f.done
+		return f.resp, f.node, true, f.err
+	}
+	f := &flight{done: make(chan struct{})}
+	g.calls[key] = f
+	g.mutex.Unlock()
+
+	f.resp, f.node, f.err = fn()
+
+	g.mutex.Lock()
+	delete(g.calls, key)
+	g.mutex.Unlock()
+	close(f.done)
+	return f.resp, f.node, false, f.err
+}
+
+// Identical reads have the same resource and payload
+func readKey(job *queue.Job) string {
+	return job.Resource.String() + "?" + string(job.Payload)
+}
+
+// coalescedRead sends job through hedgedRead, or waits on the identical
+// read already in flight and takes its answer
+func coalescedRead[T any](w *Worker, node *registry.BackendNode, method string, job *queue.Job, key string,
+	fn func(ctx context.Context, client *grpc.BackendClient) (T, error)) (T, *registry.BackendNode, error) {
+	resp, answered, shared, err := w.flights.do(key, func() (any, *registry.BackendNode, error) {
+		return hedgedRead(w, node, method, job, fn)
+	})
+	if shared {
+		coalescedReads.With(method).Inc()
+		jobLogger(answered, job.Resource, job.CRUD, job).Debug("Coalesced read")
+	}
+	typed, _ := resp.(T) // nil on errors
+	return typed, answered, err
+}
+
+// Duplicates of a read are grouped so they share one call, groups are in
+// order of first appearance
+func groupDuplicates(jobs []*queue.Job) [][]*queue.Job {
+	index := make(map[string]int)
+	var groups [][]*queue.Job
+	for _, job := range jobs {
+		key := readKey(job)
+		if i, ok := index[key]; ok {
+			groups[i] = append(groups[i], job)
+			continue
+		}
+		index[key] = len(groups)
+		groups = append(groups, []*queue.Job{job})
+	}
+	return groups
+}
+
+// shareRead gives every job of a duplicate group the answer of its one call
+func shareRead(method string, group []*queue.Job, resp any, err error) {
+	for i, job := range group {
+		if i > 0 {
+			coalescedReads.With(method).Inc()
+		}
+		job.Complete(resp, err)
+	}
+}
@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	pb "github.com/sudo-JP/Load-Manager/load-manager/api/proto/order"
+	lmgrpc "github.com/sudo-JP/Load-Manager/load-manager/internal/grpc"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+)
+
+func TestForEachJob_CoalescesDuplicates(t *testing.T) {
+	w, nodes, servers := newTestWorker(t, 0)
+	node, orders := nodes[0], servers[0]
+
+	var jobs []*queue.Job
+	for i, payload := range []string{`{"order_id":1}`, `{"order_id":2}`, `{"order_id":1}`, `{"order_id":1}`} {
+		job := &queue.Job{ID: i, Resource: queue.Order, CRUD: queue.Read, Payload: []byte(payload)}
+		job.NewReply()
+		jobs = append(jobs, job)
+	}
+
+	// No backend delay, sharing must not depend on the calls overlapping
+	var wg sync.WaitGroup
+	wg.Add(2)
+	forEachJob(node, jobs, func(group []*queue.Job) {
+		defer wg.Done()
+		resp, _, err := coalescedRead(w, node, "GetOrders", group[0], readKey(group[0]),
+			func(ctx context.Context, client *lmgrpc.BackendClient) (*pb.GetOrdersResponse, error) {
+				return client.Orders.GetOrders(ctx, &pb.GetOrdersRequest{})
+			})
+		shareRead("GetOrders", group, resp, err)
+	})
+	wg.Wait()
+
+	if n := orders.calls.Load(); n != 2 {
+		t.Errorf("Expected one call per distinct read, backend saw %d", n)
+	}
+	for _, job := range jobs {
+		if r := <-job.Reply; r.Err != nil || r.Response == nil {
+			t.Errorf("Job %d: expected the shared answer, got %+v", job.ID, r)
+		}
+	}
+}
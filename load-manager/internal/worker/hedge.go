@@ -0,0 +1,148 @@
+package worker
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/grpc"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/registry"
+)
+
+// A read sends a hedge to a second node once it has taken longer than
+// Percentile of recent reads of the same method, never before MinDelay.
+// Percentile 0 disables hedging
+type HedgePolicy struct {
+	Percentile float64
+	MinDelay   time.Duration
+}
+
+const (
+	latencySamples  = 512 // recent successful reads kept per method
+	minHedgeSamples = 20  // no hedging until the percentile means something
+)
+
+// Ring of recent latencies
+type latencyWindow struct {
+	mutex   sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+func (l *latencyWindow) observe(d time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if len(l.samples) < latencySamples {
+		l.samples = append(l.samples, d)
+		return
+	}
+	l.samples[l.next] = d
+	l.next = (l.next + 1) % latencySamples
+}
+
+func (l *latencyWindow) percentile(p float64) (time.Duration, bool) {
+	l.mutex.Lock()
+	sorted := slices.Clone(l.samples)
+	l.mutex.Unlock()
+	if len(sorted) < minHedgeSamples {
+		return 0, false
+	}
+	slices.Sort(sorted)
+	idx := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[min(len(sorted)-1, max(0, idx))], true
+}
+
+func (w *Worker) latencyWindow(method string) *latencyWindow {
+	window, _ := w.latencies.LoadOrStore(method, &latencyWindow{})
+	return window.(*latencyWindow)
+}
+
+// A second node for the hedge, nil when there is none
+func (w *Worker) hedgeNode(first *registry.BackendNode) *registry.BackendNode {
+	others := slices.DeleteFunc(w.available(), func(node *registry.BackendNode) bool {
+		return node.ID == first.ID
+	})
+	if len(others) == 0 {
+		return nil
+	}
+	return w.Selector().SelectNode(others)
+}
+
+/*
+hedgedRead calls fn on node for a read job. Past the hedge delay the same
+call goes to a second node, the first to succeed wins and the other is
+cancelled. An error only counts once both calls are done, so a hedge can
+still rescue a read whose first node failed. Returns the node that answered
+*/
+func hedgedRead[T any](w *Worker, node *registry.BackendNode, method string, job *queue.Job,
+	fn func(ctx context.Context, client *grpc.BackendClient) (T, error)) (T, *registry.BackendNode, error) {
+	policy := w.hedgePolicy()
+	window := w.latencyWindow(method)
+
+	type result struct {
+		resp T
+		node *registry.BackendNode
+		err  error
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results := make(chan result, 2)
+	send := func(node *registry.BackendNode) {
+		go func() {
+			var resp T
+			start := time.Now()
+			err := w.callContext(ctx, node, method, []*queue.Job{job},
+				func(ctx context.Context, client *grpc.BackendClient) error {
+					var err error
+					resp, err = fn(ctx, client)
+					return err
+				})
+			if err == nil && policy.Percentile > 0 {
+				window.observe(time.Since(start))
+			}
+			results <- result{resp: resp, node: node, err: err}
+		}()
+	}
+	send(node)
+
+	var hedge <-chan time.Time
+	if policy.Percentile > 0 {
+		if delay, ok := window.percentile(policy.Percentile); ok {
+			timer := time.NewTimer(max(delay, policy.MinDelay))
+			defer timer.Stop()
+			hedge = timer.C
+		}
+	}
+
+	pending := 1
+	var failed result
+	for {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				if r.node != node {
+					hedgedReads.With(method, hedgeWon).Inc()
+				}
+				return r.resp, r.node, nil
+			}
+			failed = r
+			if pending == 0 {
+				return failed.resp, failed.node, failed.err
+			}
+		case <-hedge:
+			hedge = nil
+			second := w.hedgeNode(node)
+			if second == nil {
+				continue
+			}
+			hedgedReads.With(method, hedgeSent).Inc()
+			jobLogger(second, job.Resource, job.CRUD, job).Debug("Hedging read", "first", node.Addr())
+			pending++
+			send(second)
+		}
+	}
+}
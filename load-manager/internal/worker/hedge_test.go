@@ -0,0 +1,99 @@
+package worker
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/sudo-JP/Load-Manager/load-manager/api/proto/order"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/breaker"
+	lmgrpc "github.com/sudo-JP/Load-Manager/load-manager/internal/grpc"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+)
+
+func TestLatencyWindow_Percentile(t *testing.T) {
+	var l latencyWindow
+	for i := range minHedgeSamples - 1 {
+		l.observe(time.Duration(i+1) * time.Millisecond)
+	}
+	if _, ok := l.percentile(90); ok {
+		t.Errorf("Expected no percentile before %d samples", minHedgeSamples)
+	}
+
+	l.observe(20 * time.Millisecond)
+	if p, _ := l.percentile(90); p != 18*time.Millisecond {
+		t.Errorf("Expected p90 of 1..20ms to be 18ms, got %v", p)
+	}
+
+	// Old samples roll off
+	for range latencySamples {
+		l.observe(time.Second)
+	}
+	if p, _ := l.percentile(1); p != time.Second {
+		t.Errorf("Expected only recent samples, got p1 %v", p)
+	}
+}
+
+func TestHedgedRead_SecondNodeWins(t *testing.T) {
+	w, nodes, _ := newTestWorker(t, 2*time.Second, 0)
+	slow, fast := nodes[0], nodes[1]
+	w.SetHedgePolicy(HedgePolicy{Percentile: 90, MinDelay: time.Millisecond})
+	for range minHedgeSamples {
+		w.latencyWindow("GetOrders").observe(10 * time.Millisecond)
+	}
+
+	job := &queue.Job{ID: 1, Resource: queue.Order, CRUD: queue.Read}
+	start := time.Now()
+	_, answered, err := hedgedRead(w, slow, "GetOrders", job,
+		func(ctx context.Context, client *lmgrpc.BackendClient) (*pb.GetOrdersResponse, error) {
+			return client.Orders.GetOrders(ctx, &pb.GetOrdersRequest{})
+		})
+	if err != nil {
+		t.Fatalf("Expected the hedge to answer, got %v", err)
+	}
+	if answered != fast {
+		t.Errorf("Expected %s to answer, got %s", fast.Addr(), answered.Addr())
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the hedge to beat the slow node, took %v", elapsed)
+	}
+}
+
+func TestHedgedRead_LoserKeepsBreakerHalfOpen(t *testing.T) {
+	w, nodes, _ := newTestWorker(t, 0)
+	fast := nodes[0]
+	w.registry.SetBreakerConfig(breaker.Config{FailureThreshold: 1, OpenTimeout: time.Millisecond, HalfOpenProbes: 1})
+	port, _ := serveOrders(t, 2*time.Second)
+	slow := w.registry.Add("127.0.0.1", port)
+	slow.Breaker.Failure()
+	time.Sleep(2 * time.Millisecond)
+	if state := slow.Breaker.State(); state != breaker.HalfOpen {
+		t.Fatalf("Expected the slow node half-open, got %v", state)
+	}
+
+	w.SetHedgePolicy(HedgePolicy{Percentile: 90, MinDelay: time.Millisecond})
+	for range minHedgeSamples {
+		w.latencyWindow("GetOrders").observe(10 * time.Millisecond)
+	}
+	job := &queue.Job{ID: 1, Resource: queue.Order, CRUD: queue.Read}
+	_, answered, err := hedgedRead(w, slow, "GetOrders", job,
+		func(ctx context.Context, client *lmgrpc.BackendClient) (*pb.GetOrdersResponse, error) {
+			return client.Orders.GetOrders(ctx, &pb.GetOrdersRequest{})
+		})
+	if err != nil || answered != fast {
+		t.Fatalf("Expected %s to answer, got %v", fast.Addr(), err)
+	}
+
+	// The cancelled loser finishes in the background
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&slow.ActiveReqCount) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if state := slow.Breaker.State(); state != breaker.HalfOpen {
+		t.Errorf("Expected the losing probe to leave the breaker half-open, got %v", state)
+	}
+	if !slow.Breaker.Ready() {
+		t.Errorf("Expected the losing probe to free its slot")
+	}
+}
@@ -0,0 +1,35 @@
+package worker
+
+import (
+	"log/slog"
+	"strconv"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/registry"
+)
+
+// Sent to the backend in gRPC metadata so its logs can be joined with ours
+const correlationIDKey = "x-correlation-id"
+
+func jobIDs(jobs []*queue.Job) []string {
+	ids := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		if job != nil {
+			ids = append(ids, strconv.Itoa(job.ID))
+		}
+	}
+	return ids
+}
+
+// jobLogger tags every line with the jobs, resource, operation and node it is about
+func jobLogger(node *registry.BackendNode, resource queue.JobType,
+	crud queue.Operation, jobs ...*queue.Job) *slog.Logger {
+	logger := slog.With("resource", resource.String(), "operation", crud.String())
+	if node != nil {
+		logger = logger.With("node", node.Addr())
+	}
+	if len(jobs) == 1 {
+		return logger.With("job_id", jobs[0].ID)
+	}
+	return logger.With("job_ids", jobIDs(jobs))
+}
@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"errors"
+
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/breaker"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/concurrency"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/metrics"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+)
+
+// Job outcomes
+const (
+	outcomeSuccess     = "success"
+	outcomeError       = "error"
+	outcomeInvalid     = "invalid" // payload did not unmarshal
+	outcomeNoNode      = "no_node"
+	outcomeBreakerOpen = "breaker_open"
+	outcomeLimited     = "limited" // no concurrency slot before the deadline
+)
+
+// Hedged read outcomes
+const (
+	hedgeSent = "sent"
+	hedgeWon  = "won" // the hedge answered first
+)
+
+var (
+	jobsTotal = metrics.NewCounterVec("lm_jobs_total",
+		"Jobs dispatched by resource, operation and outcome", "resource", "operation", "outcome")
+	backendLatency = metrics.NewHistogramVec("lm_backend_request_duration_seconds",
+		"gRPC call latency per node and method", metrics.DefBuckets, "node", "method")
+	backendRetries = metrics.NewCounterVec("lm_backend_retries_total",
+		"gRPC calls repeated after an Unavailable error", "node", "method")
+	hedgedReads = metrics.NewCounterVec("lm_hedged_reads_total",
+		"Reads sent to a second node after the hedge delay", "method", "outcome")
+	coalescedReads = metrics.NewCounterVec("lm_coalesced_reads_total",
+		"Reads answered by an identical read already in flight", "method")
+	workersTotal = metrics.NewGaugeVec("lm_workers",
+		"Worker goroutines")
+	workersBusy = metrics.NewGaugeVec("lm_workers_busy",
+		"Worker goroutines dispatching jobs")
+	workerBusySeconds = metrics.NewCounterVec("lm_worker_busy_seconds_total",
+		"Time spent dispatching jobs, summed over workers")
+)
+
+func recordJobs(resource queue.JobType, crud queue.Operation, outcome string, n int) {
+	if n == 0 {
+		return
+	}
+	jobsTotal.With(resource.String(), crud.String(), outcome).Add(float64(n))
+}
+
+func outcomeOf(err error) string {
+	switch {
+	case err == nil:
+		return outcomeSuccess
+	case errors.Is(err, breaker.ErrOpen):
+		return outcomeBreakerOpen
+	case errors.Is(err, concurrency.ErrLimited):
+		return outcomeLimited
+	}
+	return outcomeError
+}
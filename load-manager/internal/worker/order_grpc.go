@@ -3,54 +3,62 @@ package worker
 import (
 	"context"
 	"encoding/json"
-	"time"
 
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/grpc"
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/registry"
 
 	pb "github.com/sudo-JP/Load-Manager/load-manager/api/proto/order"
 
-	"log"
 )
 
 func (w *Worker) GetOrders(node *registry.BackendNode, 
 	jobs []*queue.Job) {
+	// Filters are optional, the gRPC front door may set any of them
 	type GetOrderDTO struct {
-		OrderID int `json:"order_id"`
+		OrderID   *int64 `json:"order_id"`
+		UserID    *int64 `json:"user_id"`
+		ProductID *int64 `json:"product_id"`
+		Page      *int32 `json:"page"`
 	}
 
-	for _, job := range jobs {
+	forEachJob(node, jobs, func(group []*queue.Job) {
+		job := group[0]
 		var dto GetOrderDTO 
 		if err := json.Unmarshal(job.Payload, &dto); err != nil {
-			log.Printf("Failed to Unmarshal order: %v", err)
-			continue
+			jobLogger(node, queue.Order, queue.Read, group...).Error("Failed to unmarshal payload", "error", err)
+			recordJobs(queue.Order, queue.Read, outcomeInvalid, len(group))
+			completeJobs(group, err)
+			return
 		}
 		
-		// Note: GetOrdersRequest requires user_id, but we only have order_id
-		// Setting user_id to -1 to return all, with order_id filter
+		// GetOrdersRequest requires user_id, HTTP only sends order_id
+		// so user_id and page default to -1 to return all
 		req := &pb.GetOrdersRequest{
-			UserId:  -1, // return all users
-			Page:    -1, // return all pages
-			OrderId: func() *int64 { id := int64(dto.OrderID); return &id }(),
+			UserId:    -1, // return all users
+			Page:      -1, // return all pages
+			OrderId:   dto.OrderID,
+			ProductId: dto.ProductID,
+		}
+		if dto.UserID != nil {
+			req.UserId = *dto.UserID
+		}
+		if dto.Page != nil {
+			req.Page = *dto.Page
 		}
 
-		go func() {
-		    ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
-		    defer cancel()
-			
-			client, err := w.getClient(node)
-			if err != nil {
-				log.Println(err)
-				return 
-			}
-			resp, err := client.Orders.GetOrders(ctx, req)
-			if err != nil {
-				log.Printf("gRPC GetOrders failed: %v", err)
-				return 
-			}
-			log.Printf("Retrieved %d orders", len(resp.Orders))
-		}()
-	}
+		resp, answered, err := cachedRead(w, node, "GetOrders", job, func(ctx context.Context, client *grpc.BackendClient) (*pb.GetOrdersResponse, error) {
+			return client.Orders.GetOrders(ctx, req)
+		})
+		recordJobs(queue.Order, queue.Read, outcomeOf(err), len(group))
+		shareRead("GetOrders", group, resp, err)
+		logger := jobLogger(answered, queue.Order, queue.Read, group...)
+		if err != nil {
+			logger.Error("gRPC GetOrders failed", "error", err)
+			return 
+		}
+		logger.Debug("Retrieved orders", "count", len(resp.Orders))
+	})
 }
 
 func (w *Worker) CreateOrders(node *registry.BackendNode,
@@ -65,7 +73,9 @@ func (w *Worker) CreateOrders(node *registry.BackendNode,
     for _, job := range jobs {
 		var dto CreateOrderDTO 
 		if err := json.Unmarshal(job.Payload, &dto); err != nil {
-			log.Printf("Failed to Unmarshal order: %v", err)
+			jobLogger(node, queue.Order, queue.Create, job).Error("Failed to unmarshal payload", "error", err)
+			recordJobs(queue.Order, queue.Create, outcomeInvalid, 1)
+			job.Complete(nil, err)
 			continue
 		}
 
@@ -84,23 +94,19 @@ func (w *Worker) CreateOrders(node *registry.BackendNode,
     	Orders: orders,
     }
 
-    ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
-    defer cancel()
-
-	client, err := w.getClient(node)
-	if err != nil {
-		log.Println(err)
-		return 
-	}
-
-	_, err = client.Orders.CreateOrders(ctx, req)
+	err := w.call(node, "CreateOrders", jobs, func(ctx context.Context, client *grpc.BackendClient) error {
+		_, err := client.Orders.CreateOrders(ctx, req)
+		return err
+	})
+	recordJobs(queue.Order, queue.Create, outcomeOf(err), len(orders))
+	completeJobs(jobs, err)
 
+	logger := jobLogger(node, queue.Order, queue.Create, jobs...)
 	if err != nil {
-		log.Printf("gRPC CreateOrders failed for node %s:%d: %v",
-			node.Host, node.Port, err)
+		logger.Error("gRPC CreateOrders failed", "error", err)
 		return 
 	}
-	log.Printf("Created orders on node %s:%d", node.Host, node.Port)
+	logger.Info("Created orders", "count", len(orders))
 }
 
 func (w *Worker) DeleteOrders(node *registry.BackendNode,
@@ -113,7 +119,9 @@ func (w *Worker) DeleteOrders(node *registry.BackendNode,
     for _, job := range jobs {
 		var dto DeleteOrderDTO 
 		if err := json.Unmarshal(job.Payload, &dto); err != nil {
-			log.Printf("Failed to Unmarshal order: %v", err)
+			jobLogger(node, queue.Order, queue.Delete, job).Error("Failed to unmarshal payload", "error", err)
+			recordJobs(queue.Order, queue.Delete, outcomeInvalid, 1)
+			job.Complete(nil, err)
 			continue
 		}
 
@@ -128,23 +136,19 @@ func (w *Worker) DeleteOrders(node *registry.BackendNode,
     	OrderIds: orderIDs,
     }
 
-    ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
-    defer cancel()
+	err := w.call(node, "DeleteOrders", jobs, func(ctx context.Context, client *grpc.BackendClient) error {
+		_, err := client.Orders.DeleteOrders(ctx, req)
+		return err
+	})
+	recordJobs(queue.Order, queue.Delete, outcomeOf(err), len(orderIDs))
+	completeJobs(jobs, err)
 
-	client, err := w.getClient(node)
+	logger := jobLogger(node, queue.Order, queue.Delete, jobs...)
 	if err != nil {
-		log.Println(err)
+		logger.Error("gRPC DeleteOrders failed", "error", err)
 		return 
 	}
-
-	_, err = client.Orders.DeleteOrders(ctx, req)
-
-	if err != nil {
-		log.Printf("gRPC DeleteOrders failed for node %s:%d: %v",
-			node.Host, node.Port, err)
-		return 
-	}
-	log.Printf("Deleted orders on node %s:%d", node.Host, node.Port)
+	logger.Info("Deleted orders", "count", len(orderIDs))
 }
 
 func (w *Worker) UpdateOrders(node *registry.BackendNode,
@@ -158,7 +162,9 @@ func (w *Worker) UpdateOrders(node *registry.BackendNode,
     for _, job := range jobs {
 		var dto UpdateOrderDTO 
 		if err := json.Unmarshal(job.Payload, &dto); err != nil {
-			log.Printf("Failed to Unmarshal order: %v", err)
+			jobLogger(node, queue.Order, queue.Update, job).Error("Failed to unmarshal payload", "error", err)
+			recordJobs(queue.Order, queue.Update, outcomeInvalid, 1)
+			job.Complete(nil, err)
 			continue
 		}
 
@@ -176,23 +182,19 @@ func (w *Worker) UpdateOrders(node *registry.BackendNode,
     	Orders: orders,
     }
 
-    ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
-    defer cancel()
-
-	client, err := w.getClient(node)
-	if err != nil {
-		log.Println(err)
-		return 
-	}
-
-	_, err = client.Orders.UpdateOrders(ctx, req)
+	err := w.call(node, "UpdateOrders", jobs, func(ctx context.Context, client *grpc.BackendClient) error {
+		_, err := client.Orders.UpdateOrders(ctx, req)
+		return err
+	})
+	recordJobs(queue.Order, queue.Update, outcomeOf(err), len(orders))
+	completeJobs(jobs, err)
 
+	logger := jobLogger(node, queue.Order, queue.Update, jobs...)
 	if err != nil {
-		log.Printf("gRPC UpdateOrders failed for node %s:%d: %v",
-			node.Host, node.Port, err)
+		logger.Error("gRPC UpdateOrders failed", "error", err)
 		return 
 	}
-	log.Printf("Updated orders on node %s:%d", node.Host, node.Port)
+	logger.Info("Updated orders", "count", len(orders))
 }
 
  
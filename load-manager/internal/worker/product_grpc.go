@@ -3,14 +3,13 @@ package worker
 import (
 	"context"
 	"encoding/json"
-	"time"
 
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/grpc"
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/registry"
 
 	pb "github.com/sudo-JP/Load-Manager/load-manager/api/proto/product"
 
-	"log"
 )
 
 func (w *Worker) GetProducts(node *registry.BackendNode, 
@@ -19,33 +18,31 @@ func (w *Worker) GetProducts(node *registry.BackendNode,
 		ProductID int `json:"product_id"`
 	}
 
-	for _, job := range jobs {
+	forEachJob(node, jobs, func(group []*queue.Job) {
+		job := group[0]
 		var dto GetProductDTO 
 		if err := json.Unmarshal(job.Payload, &dto); err != nil {
-			log.Printf("Failed to Unmarshal product: %v", err)
-			continue
+			jobLogger(node, queue.Product, queue.Read, group...).Error("Failed to unmarshal payload", "error", err)
+			recordJobs(queue.Product, queue.Read, outcomeInvalid, len(group))
+			completeJobs(group, err)
+			return
 		}
 		req := &pb.GetProductsRequest{
 			ProductId: int64(dto.ProductID),
 		}
 
-		go func() {
-		    ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
-		    defer cancel()
-			
-			client, err := w.getClient(node)
-			if err != nil {
-				log.Println(err)
-				return 
-			}
-			resp, err := client.Products.GetProducts(ctx, req)
-			if err != nil {
-				log.Printf("gRPC GetProducts failed: %v", err)
-				return 
-			}
-			log.Printf("Retrieved %d products", len(resp.Products))
-		}()
-	}
+		resp, answered, err := cachedRead(w, node, "GetProducts", job, func(ctx context.Context, client *grpc.BackendClient) (*pb.GetProductsResponse, error) {
+			return client.Products.GetProducts(ctx, req)
+		})
+		recordJobs(queue.Product, queue.Read, outcomeOf(err), len(group))
+		shareRead("GetProducts", group, resp, err)
+		logger := jobLogger(answered, queue.Product, queue.Read, group...)
+		if err != nil {
+			logger.Error("gRPC GetProducts failed", "error", err)
+			return 
+		}
+		logger.Debug("Retrieved products", "count", len(resp.Products))
+	})
 }
 
 func (w *Worker) CreateProducts(node *registry.BackendNode,
@@ -59,7 +56,9 @@ func (w *Worker) CreateProducts(node *registry.BackendNode,
     for _, job := range jobs {
 		var dto CreateProductDTO 
 		if err := json.Unmarshal(job.Payload, &dto); err != nil {
-			log.Printf("Failed to Unmarshal product: %v", err)
+			jobLogger(node, queue.Product, queue.Create, job).Error("Failed to unmarshal payload", "error", err)
+			recordJobs(queue.Product, queue.Create, outcomeInvalid, 1)
+			job.Complete(nil, err)
 			continue
 		}
 
@@ -77,23 +76,19 @@ func (w *Worker) CreateProducts(node *registry.BackendNode,
     	Products: products,
     }
 
-    ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
-    defer cancel()
-
-	client, err := w.getClient(node)
-	if err != nil {
-		log.Println(err)
-		return 
-	}
-
-	_, err = client.Products.CreateProducts(ctx, req)
+	err := w.call(node, "CreateProducts", jobs, func(ctx context.Context, client *grpc.BackendClient) error {
+		_, err := client.Products.CreateProducts(ctx, req)
+		return err
+	})
+	recordJobs(queue.Product, queue.Create, outcomeOf(err), len(products))
+	completeJobs(jobs, err)
 
+	logger := jobLogger(node, queue.Product, queue.Create, jobs...)
 	if err != nil {
-		log.Printf("gRPC CreateProducts failed for node %s:%d: %v",
-			node.Host, node.Port, err)
+		logger.Error("gRPC CreateProducts failed", "error", err)
 		return 
 	}
-	log.Printf("Created products on node %s:%d", node.Host, node.Port)
+	logger.Info("Created products", "count", len(products))
 }
 
 func (w *Worker) DeleteProducts(node *registry.BackendNode,
@@ -106,7 +101,9 @@ func (w *Worker) DeleteProducts(node *registry.BackendNode,
     for _, job := range jobs {
 		var dto DeleteProductDTO 
 		if err := json.Unmarshal(job.Payload, &dto); err != nil {
-			log.Printf("Failed to Unmarshal product: %v", err)
+			jobLogger(node, queue.Product, queue.Delete, job).Error("Failed to unmarshal payload", "error", err)
+			recordJobs(queue.Product, queue.Delete, outcomeInvalid, 1)
+			job.Complete(nil, err)
 			continue
 		}
 
@@ -121,23 +118,19 @@ func (w *Worker) DeleteProducts(node *registry.BackendNode,
     	ProductIds: productIDs,
     }
 
-    ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
-    defer cancel()
+	err := w.call(node, "DeleteProducts", jobs, func(ctx context.Context, client *grpc.BackendClient) error {
+		_, err := client.Products.DeleteProducts(ctx, req)
+		return err
+	})
+	recordJobs(queue.Product, queue.Delete, outcomeOf(err), len(productIDs))
+	completeJobs(jobs, err)
 
-	client, err := w.getClient(node)
+	logger := jobLogger(node, queue.Product, queue.Delete, jobs...)
 	if err != nil {
-		log.Println(err)
+		logger.Error("gRPC DeleteProducts failed", "error", err)
 		return 
 	}
-
-	_, err = client.Products.DeleteProducts(ctx, req)
-
-	if err != nil {
-		log.Printf("gRPC DeleteProducts failed for node %s:%d: %v",
-			node.Host, node.Port, err)
-		return 
-	}
-	log.Printf("Deleted products on node %s:%d", node.Host, node.Port)
+	logger.Info("Deleted products", "count", len(productIDs))
 }
 
 func (w *Worker) UpdateProducts(node *registry.BackendNode,
@@ -152,7 +145,9 @@ func (w *Worker) UpdateProducts(node *registry.BackendNode,
     for _, job := range jobs {
 		var dto UpdateProductDTO 
 		if err := json.Unmarshal(job.Payload, &dto); err != nil {
-			log.Printf("Failed to Unmarshal product: %v", err)
+			jobLogger(node, queue.Product, queue.Update, job).Error("Failed to unmarshal payload", "error", err)
+			recordJobs(queue.Product, queue.Update, outcomeInvalid, 1)
+			job.Complete(nil, err)
 			continue
 		}
 
@@ -171,21 +166,17 @@ func (w *Worker) UpdateProducts(node *registry.BackendNode,
     	Products: products,
     }
 
-    ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
-    defer cancel()
-
-	client, err := w.getClient(node)
-	if err != nil {
-		log.Println(err)
-		return 
-	}
-
-	_, err = client.Products.UpdateProducts(ctx, req)
+	err := w.call(node, "UpdateProducts", jobs, func(ctx context.Context, client *grpc.BackendClient) error {
+		_, err := client.Products.UpdateProducts(ctx, req)
+		return err
+	})
+	recordJobs(queue.Product, queue.Update, outcomeOf(err), len(products))
+	completeJobs(jobs, err)
 
+	logger := jobLogger(node, queue.Product, queue.Update, jobs...)
 	if err != nil {
-		log.Printf("gRPC UpdateProducts failed for node %s:%d: %v",
-			node.Host, node.Port, err)
+		logger.Error("gRPC UpdateProducts failed", "error", err)
 		return 
 	}
-	log.Printf("Updated products on node %s:%d", node.Host, node.Port)
+	logger.Info("Updated products", "count", len(products))
 }
@@ -3,14 +3,13 @@ package worker
 import (
 	"context"
 	"encoding/json"
-	"time"
 
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/grpc"
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/registry"
 
 	pb "github.com/sudo-JP/Load-Manager/load-manager/api/proto/user"
 
-	"log"
 )
 
 
@@ -20,34 +19,32 @@ func (w *Worker) GetUsers(node *registry.BackendNode,
 		Email string `json:"email"`
 	}
 
-	for _, job := range jobs {
+	forEachJob(node, jobs, func(group []*queue.Job) {
+		job := group[0]
 		var dto GetUserDTO 
 		if err := json.Unmarshal(job.Payload, &dto); err != nil {
-			log.Printf("Failed to Unmarshal user: %v", err)
-			continue
+			jobLogger(node, queue.User, queue.Read, group...).Error("Failed to unmarshal payload", "error", err)
+			recordJobs(queue.User, queue.Read, outcomeInvalid, len(group))
+			completeJobs(group, err)
+			return
 		}
 		req := &pb.GetUsersRequest{
 			Email: dto.Email,
 		}
 
-		go func() {
-		    ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
-		    defer cancel()
-			
-			client, err := w.getClient(node)
-			if err != nil {
-				log.Println(err)
-				return 
-			}
-			resp, err := client.Users.GetUsers(ctx, req)
-			if err != nil {
-				log.Printf("gRPC GetUsers failed: %v", err)
-				return 
-			}
-			log.Printf("Retrived %d users", len(resp.Users))
-		}()
+		resp, answered, err := cachedRead(w, node, "GetUsers", job, func(ctx context.Context, client *grpc.BackendClient) (*pb.GetUsersResponse, error) {
+			return client.Users.GetUsers(ctx, req)
+		})
+		recordJobs(queue.User, queue.Read, outcomeOf(err), len(group))
+		shareRead("GetUsers", group, resp, err)
+		logger := jobLogger(answered, queue.User, queue.Read, group...)
+		if err != nil {
+			logger.Error("gRPC GetUsers failed", "error", err)
+			return 
+		}
+		logger.Debug("Retrieved users", "count", len(resp.Users))
 
-	}
+	})
 
 
 }
@@ -65,7 +62,9 @@ func (w *Worker) CreateUsers(node *registry.BackendNode,
     for _, job := range jobs {
 		var dto CreateUserDTO 
 		if err := json.Unmarshal(job.Payload, &dto); err != nil {
-			log.Printf("Failed to Unmarshal user: %v", err)
+			jobLogger(node, queue.User, queue.Create, job).Error("Failed to unmarshal payload", "error", err)
+			recordJobs(queue.User, queue.Create, outcomeInvalid, 1)
+			job.Complete(nil, err)
 			continue
 		}
 
@@ -87,23 +86,19 @@ func (w *Worker) CreateUsers(node *registry.BackendNode,
     }
 
     // Send grpc 
-    ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
-    defer cancel()
-
-	client, err := w.getClient(node)
+	err := w.call(node, "CreateUsers", jobs, func(ctx context.Context, client *grpc.BackendClient) error {
+		_, err := client.Users.CreateUsers(ctx, req)
+		return err
+	})
+	recordJobs(queue.User, queue.Create, outcomeOf(err), len(users))
+	completeJobs(jobs, err)
+
+	logger := jobLogger(node, queue.User, queue.Create, jobs...)
 	if err != nil {
-		log.Println(err)
+		logger.Error("gRPC CreateUsers failed", "error", err)
 		return 
 	}
-
-	_, err = client.Users.CreateUsers(ctx, req)
-
-	if err != nil {
-		log.Printf("gRPC CreateUsers failed for node %s:%d: %v",
-			node.Host, node.Port, err)
-		return 
-	}
-	log.Printf("Created users on mode %s:%d", node.Host, node.Port)
+	logger.Info("Created users", "count", len(users))
 }
 
 func (w *Worker) DeleteUsers(node *registry.BackendNode,
@@ -119,7 +114,9 @@ func (w *Worker) DeleteUsers(node *registry.BackendNode,
     for _, job := range jobs {
 		var dto DeleteUserDTO 
 		if err := json.Unmarshal(job.Payload, &dto); err != nil {
-			log.Printf("Failed to Unmarshal user: %v", err)
+			jobLogger(node, queue.User, queue.Delete, job).Error("Failed to unmarshal payload", "error", err)
+			recordJobs(queue.User, queue.Delete, outcomeInvalid, 1)
+			job.Complete(nil, err)
 			continue
 		}
 
@@ -141,23 +138,19 @@ func (w *Worker) DeleteUsers(node *registry.BackendNode,
     }
 
     // Send grpc 
-    ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
-    defer cancel()
-
-	client, err := w.getClient(node)
+	err := w.call(node, "DeleteUsers", jobs, func(ctx context.Context, client *grpc.BackendClient) error {
+		_, err := client.Users.DeleteUsers(ctx, req)
+		return err
+	})
+	recordJobs(queue.User, queue.Delete, outcomeOf(err), len(users))
+	completeJobs(jobs, err)
+
+	logger := jobLogger(node, queue.User, queue.Delete, jobs...)
 	if err != nil {
-		log.Println(err)
+		logger.Error("gRPC DeleteUsers failed", "error", err)
 		return 
 	}
-
-	_, err = client.Users.DeleteUsers(ctx, req)
-
-	if err != nil {
-		log.Printf("gRPC CreateUsers failed for node %s:%d: %v",
-			node.Host, node.Port, err)
-		return 
-	}
-	log.Printf("Deleted users on mode %s:%d", node.Host, node.Port)
+	logger.Info("Deleted users", "count", len(users))
 }
 
 func (w *Worker) UpdateUsers(node *registry.BackendNode,
@@ -173,7 +166,9 @@ func (w *Worker) UpdateUsers(node *registry.BackendNode,
     for _, job := range jobs {
 		var dto UpdateUserDTO 
 		if err := json.Unmarshal(job.Payload, &dto); err != nil {
-			log.Printf("Failed to Unmarshal user: %v", err)
+			jobLogger(node, queue.User, queue.Update, job).Error("Failed to unmarshal payload", "error", err)
+			recordJobs(queue.User, queue.Update, outcomeInvalid, 1)
+			job.Complete(nil, err)
 			continue
 		}
 
@@ -195,22 +190,18 @@ func (w *Worker) UpdateUsers(node *registry.BackendNode,
     }
 
     // Send grpc 
-    ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
-    defer cancel()
-
-	client, err := w.getClient(node)
-	if err != nil {
-		log.Println(err)
-		return 
-	}
-
-	_, err = client.Users.UpdateUsers(ctx, req)
-
+	err := w.call(node, "UpdateUsers", jobs, func(ctx context.Context, client *grpc.BackendClient) error {
+		_, err := client.Users.UpdateUsers(ctx, req)
+		return err
+	})
+	recordJobs(queue.User, queue.Update, outcomeOf(err), len(users))
+	completeJobs(jobs, err)
+
+	logger := jobLogger(node, queue.User, queue.Update, jobs...)
 	if err != nil {
-		log.Printf("gRPC CreateUsers failed for node %s:%d: %v",
-			node.Host, node.Port, err)
+		logger.Error("gRPC UpdateUsers failed", "error", err)
 		return 
 	}
-	log.Printf("Updated users on mode %s:%d", node.Host, node.Port)
+	logger.Info("Updated users", "count", len(users))
 }
 
@@ -1,67 +1,135 @@
 package worker
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/sudo-JP/Load-Manager/common/tracing"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/cache"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/concurrency"
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/grpc"
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/registry"
 	"github.com/sudo-JP/Load-Manager/load-manager/internal/selector"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/strategy"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
-
-	"log"
+	"log/slog"
 )
 
-type LoadBalancingStrategy int 
-const (
-	Mixed 					LoadBalancingStrategy = iota
-	PerResource
-	PerOperation
-	PerResourceAndOperation
-)
+// Attempts counts the first call, only reads failing with Unavailable are
+// retried. A write may have been committed before the connection dropped
+type RetryPolicy struct {
+	Attempts int
+	Backoff  time.Duration // grows linearly with each attempt
+}
+
+const defaultCallTimeout = 5 * time.Second
+
+// Status of one worker goroutine, Since is when it went busy or idle
+type GoroutineStatus struct {
+	ID      int       `json:"id"`
+	Busy    bool      `json:"busy"`
+	Since   time.Time `json:"since"`
+	Batches uint64    `json:"batches"`
+}
+
+type goroutineState struct {
+	busy    atomic.Bool
+	since   atomic.Int64 // unix nano
+	batches atomic.Uint64
+}
+
+func (g *goroutineState) set(busy bool) {
+	g.busy.Store(busy)
+	g.since.Store(time.Now().UnixNano())
+}
 
 type Worker struct {
 	queue 		queue.Queue
 	registry 	*registry.Registry
 	selector 	selector.Selector	
-	clients 	map[string]*grpc.BackendClient // key is host:port
+	strategy 	strategy.Strategy
+	callTimeout 	time.Duration
+	retry 		RetryPolicy
+	hedge 		HedgePolicy
+	cache 		*cache.Cache // read responses, nil when off
+	observer 	BatchObserver // nil when nothing listens
+	confMut 	sync.RWMutex // guards selector, strategy, timeout, retry, hedge, cache and observer, all can change live
+	latencies 	sync.Map // method -> *latencyWindow, for hedging reads
+	flights 	flightGroup // identical reads in flight
+	clients 	map[int]*grpc.BackendClient // key is node id
+	creds 		credentials.TransportCredentials // for new clients, nil is plaintext
 	clientsMut 	sync.RWMutex	
 	stopCh 		chan struct{}
 	workers 	int 
-	strategy 	LoadBalancingStrategy
+	states 		[]*goroutineState
+	stopped 	atomic.Bool
 }
 
 
-func groupByResource(jobs []*queue.Job) map[queue.JobType][]*queue.Job {
-	grouped := make(map[queue.JobType][]*queue.Job)
+var ErrNoNode = errors.New("no available nodes")
 
-	for _, job := range jobs {
-		// error case 
-		if job == nil {
-			continue
+// Nodes at their concurrency limit are skipped while others have room,
+// when all are full the selected node's calls wait for a slot
+func (w *Worker) available() []*registry.BackendNode {
+	nodes := w.registry.Available()
+	free := make([]*registry.BackendNode, 0, len(nodes))
+	for _, node := range nodes {
+		if node.Limiter.Free() {
+			free = append(free, node)
 		}
-			
-		grouped[job.Resource] = append(grouped[job.Resource], job)
 	}
-	return grouped
+	if len(free) == 0 {
+		return nodes
+	}
+	return free
 }
 
-func groupByCRUD(jobs []*queue.Job) map[queue.Operation][]*queue.Job {
-	grouped := make(map[queue.Operation][]*queue.Job)
+/*
+Reads are a call per distinct query, at most the node's concurrency limit
+of goroutines run them, or one per query when it is unbounded. fn gets
+the identical reads of jobs together and makes one call for all of them,
+see shareRead
+*/
+func forEachJob(node *registry.BackendNode, jobs []*queue.Job, fn func(group []*queue.Job)) {
+	groups := groupDuplicates(jobs)
+	n := len(groups)
+	if limit := node.Limiter.Limit(); limit > 0 {
+		n = min(n, limit)
+	}
+	var next atomic.Int64
+	for range n {
+		go func() {
+			for i := next.Add(1) - 1; i < int64(len(groups)); i = next.Add(1) - 1 {
+				fn(groups[i])
+			}
+		}()
+	}
+}
 
+func recordNoNode(jobs []*queue.Job) {
 	for _, job := range jobs {
-		if job == nil {
-			continue
+		if job != nil {
+			recordJobs(job.Resource, job.CRUD, outcomeNoNode, 1)
+			job.Complete(nil, ErrNoNode)
 		}
-
-		grouped[job.CRUD] = append(grouped[job.CRUD], job)
-}
-	return grouped
+	}
 }
 
+// Reports one outcome for every job of a batch call
+func completeJobs(jobs []*queue.Job, err error) {
+	for _, job := range jobs {
+		job.Complete(nil, err)
+	}
+}
 
 func (w *Worker) sendToBackend(node *registry.BackendNode, resource queue.JobType, 
 	crud queue.Operation, jobs []*queue.Job) {
@@ -69,8 +137,11 @@ func (w *Worker) sendToBackend(node *registry.BackendNode, resource queue.JobTyp
 		return 
 	}
 
-	log.Printf("Sending %d %v %v jobs to node %s:%d",
-		len(jobs), resource, crud, node.Host, node.Port)
+	jobLogger(node, resource, crud, jobs...).Debug("Sending jobs to node", "count", len(jobs))
+
+	if crud != queue.Read {
+		defer w.invalidate(resource)()
+	}
 	
 	switch resource {
 	case queue.User: 
@@ -82,80 +153,19 @@ func (w *Worker) sendToBackend(node *registry.BackendNode, resource queue.JobTyp
 	}
 }
 
-func (w *Worker) mixedStat(jobs []*queue.Job) error {
-	node := w.selector.SelectNode(w.registry.All())
-	if node == nil {
-		return errors.New("no available nodes")
-	}
-	// just optimization for job same type and operation to be tgt  
-	grouped := groupByResource(jobs)		
-	for resource, resourceJob := range grouped {
-		groupedCRUD := groupByCRUD(resourceJob)
-		for crud, crudJobs := range groupedCRUD {
-			w.sendToBackend(node, resource, crud, crudJobs)
-		}
-	}
-	return nil 
-}
-
-func (w *Worker) perOperationStrat(jobs []*queue.Job) {
-	groupedCRUD := groupByCRUD(jobs)
-	for crud, crudJobs := range groupedCRUD {
-		node := w.selector.SelectNode(w.registry.All())
-
-		if node == nil {
+// Sends each of the strategy's assignments, jobs with no node are dropped
+func (w *Worker) dispatch(jobs []*queue.Job) {
+	for _, a := range w.Strategy().Assign(jobs, w.available(), w.Selector()) {
+		if a.Node == nil {
+			recordNoNode(a.Jobs)
+			slog.Warn("Dropping jobs", "error", ErrNoNode, "job_ids", jobIDs(a.Jobs))
 			continue
 		}
-
-		grouped := groupByResource(crudJobs)
-		for resource, resourceJobs := range grouped {
-			w.sendToBackend(node, resource, crud, resourceJobs)
-		}
-	}	
-}
-
-func (w *Worker) perResourceStrat(jobs []*queue.Job) {
-	groupedResource := groupByResource(jobs)
-	// optimization 
-	for resource, resourceJobs := range groupedResource {
-		node := w.selector.SelectNode(w.registry.All())
-		if node == nil {
-			continue 
-		}			
-		
-		grouped := groupByCRUD(resourceJobs)
-		for crud, crudJobs := range grouped {
-			w.sendToBackend(node, resource, crud, crudJobs)
-		}
-	}	
-}
-
-func (w *Worker) perOperationAndResouceStrat(jobs []*queue.Job) error {
-	groupedCRUD := groupByCRUD(jobs)	
-
-	// per crud 
-	for crud, crudJobs := range groupedCRUD {
-
-		// per resource 
-		grouped := groupByResource(crudJobs)
-		for resource, resourceJobs := range grouped {
-
-			// each type, we get a new node and send to backend 
-			node := w.selector.SelectNode(w.registry.All())
-
-			if node == nil {
-				return errors.New("no available nodes")
-			}
-
-			w.sendToBackend(node, resource, crud, resourceJobs)
-
-		}
-
+		w.sendToBackend(a.Node, a.Resource, a.CRUD, a.Jobs)
 	}
-	return nil 
 }
 
-func (w *Worker) run() {
+func (w *Worker) run(state *goroutineState) {
 	for {
 		select {
 		case <- w.stopCh: 
@@ -165,47 +175,33 @@ func (w *Worker) run() {
 
 		jobs, errs := w.queue.Pops() 
 		if len(errs) > 0 {
-			log.Printf("Errors popping from queue %v", errs)
+			slog.Error("Errors popping from queue", "errors", errs)
 		}
 
 		if len(jobs) == 0 {
 			time.Sleep(10 * time.Millisecond) // sleep so no cpu waste 
 			continue
 		}
+		traceQueueWait(jobs)
 
-		// pick strategy
-		switch w.strategy {
-
-		// One node for all operations and resource
-		case Mixed: 
-			err := w.mixedStat(jobs)	
-			if err != nil {
-				log.Println(err)
-			}
-
-		// One node per operation 
-		case PerOperation: 
-			w.perOperationStrat(jobs)
+		state.set(true)
+		workersBusy.With().Inc()
+		start := time.Now()
 
-		// One node per resource 
-		case PerResource:
-			w.perResourceStrat(jobs)
+		w.dispatch(jobs)
 
-		// One node per resouce and operation 
-		case PerResourceAndOperation: 
-			err := w.perOperationAndResouceStrat(jobs)
-			if err != nil {
-				log.Println(err)	
-			}	
-		}
+		workerBusySeconds.With().Add(time.Since(start).Seconds())
+		workersBusy.With().Dec()
+		state.batches.Add(1)
+		state.set(false)
 	}
 }
 
+// Clients are per node, not per address, so a node re-added at the same
+// address while the old one drains gets its own
 func (w *Worker) getClient(node *registry.BackendNode) (*grpc.BackendClient, error) {
-	addr := fmt.Sprintf("%s:%d", node.Host, node.Port)
-
 	w.clientsMut.RLock()
-	client, ok := w.clients[addr]
+	client, ok := w.clients[node.ID]
 	w.clientsMut.RUnlock()
 
 	if ok {
@@ -216,15 +212,185 @@ func (w *Worker) getClient(node *registry.BackendNode) (*grpc.BackendClient, err
 	w.clientsMut.Lock()
 	defer w.clientsMut.Unlock()
 
-	client, err := grpc.NewBackendClient(addr)
+	// Another goroutine may have connected in the meantime
+	if client, ok := w.clients[node.ID]; ok {
+		return client, nil
+	}
+
+	// Removed nodes must not get a new connection. Checked under the lock
+	// closeClient takes after removing, so no client outlives its node
+	if w.registry.Get(node.ID) != node {
+		return nil, fmt.Errorf("node %s was removed", node.Addr())
+	}
+
+	client, err := grpc.NewBackendClient(node.Addr(), w.creds)
 	if err != nil {
 		return nil, err
 	}
 
-	w.clients[addr] = client
+	w.clients[node.ID] = client
 	return client, nil
 }
 
+// Only transport level errors count against the node, not
+// application errors like "user not found"
+func isBackendFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, 
+		codes.ResourceExhausted, codes.Internal:
+		return true
+	}
+	return false
+}
+
+// Overload shrinks the concurrency limit, a node that is down says nothing about it
+func limitOutcome(err error) concurrency.Outcome {
+	switch status.Code(err) {
+	case codes.DeadlineExceeded, codes.ResourceExhausted:
+		return concurrency.Dropped
+	case codes.Unavailable, codes.Internal, codes.Canceled:
+		return concurrency.Ignored
+	}
+	return concurrency.Success
+}
+
+// Time from job creation to pop, batcher time included
+func traceQueueWait(jobs []*queue.Job) {
+	now := time.Now()
+	for _, job := range jobs {
+		if job == nil {
+			continue
+		}
+		_, span := tracing.Start(context.Background(), "queue.wait",
+			tracing.WithParent(job.Trace),
+			tracing.WithStart(job.CreatedAt),
+			tracing.WithAttr("job.id", job.ID))
+		span.EndAt(now)
+	}
+}
+
+/*
+call runs fn against the node's client, guarded by the node's circuit breaker.
+One gRPC call can serve jobs from many requests, so the call span sits in the
+first job's trace and links the others, each job gets its own dispatch span.
+*/
+func (w *Worker) call(node *registry.BackendNode, method string, jobs []*queue.Job,
+	fn func(ctx context.Context, client *grpc.BackendClient) error) error {
+	return w.callContext(context.Background(), node, method, jobs, fn)
+}
+
+// callContext is call with a parent context, cancelling it abandons the call
+func (w *Worker) callContext(ctx context.Context, node *registry.BackendNode, method string,
+	jobs []*queue.Job, fn func(ctx context.Context, client *grpc.BackendClient) error) (err error) {
+	var parent tracing.SpanContext
+	if len(jobs) > 0 {
+		parent = jobs[0].Trace
+	}
+	ctx, span := tracing.Start(ctx, "grpc.client",
+		tracing.WithParent(parent),
+		tracing.WithAttr("rpc.method", method),
+		tracing.WithAttr("node", node.Addr()),
+		tracing.WithAttr("batch.size", len(jobs)))
+	for _, job := range jobs[min(1, len(jobs)):] {
+		span.AddLink(job.Trace)
+	}
+
+	start := time.Now()
+	defer func() {
+		if span == nil {
+			return // tracing off
+		}
+		span.SetError(err)
+		span.End()
+		for _, job := range jobs {
+			_, dispatch := tracing.Start(context.Background(), "worker.dispatch",
+				tracing.WithParent(job.Trace),
+				tracing.WithStart(start),
+				tracing.WithAttr("job.id", job.ID),
+				tracing.WithAttr("node", node.Addr()))
+			dispatch.AddLink(span.Context())
+			dispatch.SetError(err)
+			dispatch.End()
+		}
+	}()
+
+	timeout, retry := w.callOptions()
+	read := len(jobs) > 0 && jobs[0].CRUD == queue.Read
+	for attempt := 1; ; attempt++ {
+		sent := time.Now()
+		err = w.attempt(ctx, node, method, jobs, timeout, fn)
+		if !read || attempt >= retry.Attempts || status.Code(err) != codes.Unavailable {
+			span.SetAttr("rpc.attempts", attempt)
+			if err == nil {
+				w.observeBatch(jobs, time.Since(sent))
+			}
+			return err
+		}
+		backendRetries.With(node.Addr(), method).Inc()
+
+		// A cancelled hedge stops here instead of calling again
+		backoff := time.NewTimer(retry.Backoff * time.Duration(attempt))
+		select {
+		case <-backoff.C:
+		case <-ctx.Done():
+			backoff.Stop()
+			span.SetAttr("rpc.attempts", attempt)
+			return err
+		}
+	}
+}
+
+func (w *Worker) attempt(ctx context.Context, node *registry.BackendNode, method string,
+	jobs []*queue.Job, timeout time.Duration,
+	fn func(ctx context.Context, client *grpc.BackendClient) error) error {
+	// Waiting for a slot counts against the attempt's deadline
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := node.Limiter.Acquire(ctx); err != nil {
+		return fmt.Errorf("node %s:%d: %w", node.Host, node.Port, err)
+	}
+	outcome, callStart := concurrency.Ignored, time.Now()
+	defer func() { node.Limiter.Release(time.Since(callStart), outcome) }()
+
+	if err := node.Breaker.Allow(); err != nil {
+		return fmt.Errorf("node %s:%d: %w", node.Host, node.Port, err)
+	}
+
+	atomic.AddInt32(&node.ActiveReqCount, 1)
+	defer atomic.AddInt32(&node.ActiveReqCount, -1)
+
+	client, err := w.getClient(node)
+	if err != nil {
+		node.Breaker.Failure()
+		return err
+	}
+
+	// Job ids double as correlation ids in backend logs
+	for _, id := range jobIDs(jobs) {
+		ctx = metadata.AppendToOutgoingContext(ctx, correlationIDKey, id)
+	}
+
+	callStart = time.Now()
+	err = fn(ctx, client)
+	backendLatency.With(node.Addr(), method).
+		Observe(time.Since(callStart).Seconds())
+	outcome = limitOutcome(err)
+
+	// A cancelled call, like a hedge that lost, never heard back from the node
+	switch {
+	case status.Code(err) == codes.Canceled || errors.Is(ctx.Err(), context.Canceled):
+		node.Breaker.Ignore()
+	case isBackendFailure(err):
+		node.Breaker.Failure()
+	default:
+		node.Breaker.Success()
+	}
+	return err
+}
+
 func (w *Worker) sendUserJobs(node *registry.BackendNode, 
 	crud queue.Operation, jobs []*queue.Job) {
 	switch crud {
@@ -267,12 +433,150 @@ func (w *Worker) sendOrdersJobs(node *registry.BackendNode,
 	}
 }
 
+func (w *Worker) closeClient(node *registry.BackendNode) {
+	w.clientsMut.Lock()
+	client, ok := w.clients[node.ID]
+	delete(w.clients, node.ID)
+	w.clientsMut.Unlock()
+
+	if ok {
+		client.Close()
+	}
+}
+
+/*
+RemoveNode takes the node out of the registry so no new jobs are selected for it,
+waits up to timeout for its in-flight calls to finish, then closes its client
+*/
+func (w *Worker) RemoveNode(id int, timeout time.Duration) error {
+	node := w.registry.Get(id)
+	if node == nil {
+		return fmt.Errorf("node %d not found", id)
+	}
+	w.registry.Remove(id)
+
+	deadline := time.Now().Add(timeout)
+	for atomic.LoadInt32(&node.ActiveReqCount) > 0 {
+		if time.Now().After(deadline) {
+			slog.Warn("Node still has requests in flight after drain timeout",
+				"node", node.Addr(), "in_flight", atomic.LoadInt32(&node.ActiveReqCount))
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	w.closeClient(node)
+	slog.Info("Removed node", "node", node.Addr())
+	return nil
+}
+
+func (w *Worker) Selector() selector.Selector {
+	w.confMut.RLock()
+	defer w.confMut.RUnlock()
+	return w.selector
+}
+
+func (w *Worker) Strategy() strategy.Strategy {
+	w.confMut.RLock()
+	defer w.confMut.RUnlock()
+	return w.strategy
+}
+
+// A batch being dispatched keeps the strategy it started with
+func (w *Worker) SetSelector(s selector.Selector) {
+	w.confMut.Lock()
+	defer w.confMut.Unlock()
+	w.selector = s
+}
+
+func (w *Worker) SetStrategy(strat strategy.Strategy) {
+	w.confMut.Lock()
+	defer w.confMut.Unlock()
+	w.strategy = strat
+}
+
+// SetTransportCredentials applies to connections opened afterwards
+func (w *Worker) SetTransportCredentials(creds credentials.TransportCredentials) {
+	w.clientsMut.Lock()
+	defer w.clientsMut.Unlock()
+	w.creds = creds
+}
+
+// Per attempt deadline for gRPC calls
+func (w *Worker) SetCallTimeout(timeout time.Duration) {
+	w.confMut.Lock()
+	defer w.confMut.Unlock()
+	w.callTimeout = timeout
+}
+
+func (w *Worker) SetRetryPolicy(policy RetryPolicy) {
+	w.confMut.Lock()
+	defer w.confMut.Unlock()
+	w.retry = policy
+}
+
+// BatchObserver learns how long each successful batch write took
+type BatchObserver func(resource queue.JobType, items int, latency time.Duration)
+
+func (w *Worker) SetBatchObserver(observer BatchObserver) {
+	w.confMut.Lock()
+	defer w.confMut.Unlock()
+	w.observer = observer
+}
+
+// Reads are a call per job, only writes tell how batch size affects the backend
+func (w *Worker) observeBatch(jobs []*queue.Job, latency time.Duration) {
+	w.confMut.RLock()
+	observer := w.observer
+	w.confMut.RUnlock()
+	if observer == nil || len(jobs) == 0 || jobs[0].CRUD == queue.Read {
+		return
+	}
+	observer(jobs[0].Resource, len(jobs), latency)
+}
+
+func (w *Worker) SetHedgePolicy(policy HedgePolicy) {
+	w.confMut.Lock()
+	defer w.confMut.Unlock()
+	w.hedge = policy
+}
+
+func (w *Worker) hedgePolicy() HedgePolicy {
+	w.confMut.RLock()
+	defer w.confMut.RUnlock()
+	return w.hedge
+}
+
+func (w *Worker) callOptions() (time.Duration, RetryPolicy) {
+	w.confMut.RLock()
+	defer w.confMut.RUnlock()
+	return w.callTimeout, w.retry
+}
+
 func (w *Worker) Stop() {
+	w.stopped.Store(true)
 	close(w.stopCh)
 }
 
+func (w *Worker) Stopped() bool {
+	return w.stopped.Load()
+}
+
+func (w *Worker) Status() []GoroutineStatus {
+	result := make([]GoroutineStatus, len(w.states))
+	for i, state := range w.states {
+		result[i] = GoroutineStatus{
+			ID:      i,
+			Busy:    state.busy.Load(),
+			Since:   time.Unix(0, state.since.Load()),
+			Batches: state.batches.Load(),
+		}
+	}
+	return result
+}
+
 func NewWorker(q queue.Queue, reg *registry.Registry, selector selector.Selector, 
-	clients map[string]*grpc.BackendClient, workers int, strat LoadBalancingStrategy) *Worker {
+	clients map[int]*grpc.BackendClient, workers int, strat strategy.Strategy) *Worker {
 	w := &Worker{
 		queue: 		q, 
 		registry: 	reg, 
@@ -281,10 +585,16 @@ func NewWorker(q queue.Queue, reg *registry.Registry, selector selector.Selector
 		workers: 	workers, 
 		stopCh: 	make(chan struct{}), 
 		strategy: 	strat, 
+		callTimeout: 	defaultCallTimeout,
+		retry: 		RetryPolicy{Attempts: 1},
 	}
 
+	workersTotal.With().Set(float64(workers))
 	for range workers {
-		go w.run()
+		state := &goroutineState{}
+		state.set(false)
+		w.states = append(w.states, state)
+		go w.run(state)
 	}
 	return w
 }
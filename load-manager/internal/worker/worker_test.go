@@ -0,0 +1,139 @@
+package worker
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/sudo-JP/Load-Manager/load-manager/api/proto/order"
+	lmgrpc "github.com/sudo-JP/Load-Manager/load-manager/internal/grpc"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/queue"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/registry"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/selector"
+	"github.com/sudo-JP/Load-Manager/load-manager/internal/strategy"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type slowOrders struct {
+	pb.UnimplementedOrderServiceServer
+	delay time.Duration
+	calls atomic.Int32
+}
+
+func (s *slowOrders) GetOrders(ctx context.Context, req *pb.GetOrdersRequest) (*pb.GetOrdersResponse, error) {
+	s.calls.Add(1)
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &pb.GetOrdersResponse{}, nil
+}
+
+// Serves orders after delay, returns its port
+func serveOrders(t *testing.T, delay time.Duration) (int, *slowOrders) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	orders := &slowOrders{delay: delay}
+	pb.RegisterOrderServiceServer(srv, orders)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis.Addr().(*net.TCPAddr).Port, orders
+}
+
+// A worker with a node for each delay, each serving orders after it
+func newTestWorker(t *testing.T, delays ...time.Duration) (*Worker, []*registry.BackendNode, []*slowOrders) {
+	t.Helper()
+	reg := registry.NewRegistry()
+	sel, _ := selector.NewSelector("RR")
+	w := NewWorker(nil, reg, sel, map[int]*lmgrpc.BackendClient{}, 0, strategy.Mixed{})
+
+	var nodes []*registry.BackendNode
+	var servers []*slowOrders
+	for _, delay := range delays {
+		port, orders := serveOrders(t, delay)
+		nodes = append(nodes, reg.Add("127.0.0.1", port))
+		servers = append(servers, orders)
+	}
+	return w, nodes, servers
+}
+
+func TestCallContext_RetriesOnlyReads(t *testing.T) {
+	w, _, _ := newTestWorker(t)
+	node := w.registry.Add("127.0.0.1", 1) // nothing listens there
+	w.SetRetryPolicy(RetryPolicy{Attempts: 3})
+
+	for _, tt := range []struct {
+		crud  queue.Operation
+		calls int
+	}{
+		{queue.Read, 3},
+		{queue.Create, 1}, // may have been committed before the connection dropped
+		{queue.Delete, 1},
+	} {
+		calls := 0
+		jobs := []*queue.Job{{ID: 1, Resource: queue.User, CRUD: tt.crud}}
+		err := w.call(node, "Test", jobs, func(ctx context.Context, client *lmgrpc.BackendClient) error {
+			calls++
+			return status.Error(codes.Unavailable, "connection reset")
+		})
+		if status.Code(err) != codes.Unavailable {
+			t.Errorf("%s: expected Unavailable, got %v", tt.crud, err)
+		}
+		if calls != tt.calls {
+			t.Errorf("%s: expected %d calls, got %d", tt.crud, tt.calls, calls)
+		}
+	}
+}
+
+func TestCallContext_BackoffStopsOnCancel(t *testing.T) {
+	w, _, _ := newTestWorker(t)
+	node := w.registry.Add("127.0.0.1", 1) // nothing listens there
+	w.SetRetryPolicy(RetryPolicy{Attempts: 3, Backoff: time.Hour})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	jobs := []*queue.Job{{ID: 1, Resource: queue.User, CRUD: queue.Read}}
+	start := time.Now()
+	w.callContext(ctx, node, "Test", jobs, func(ctx context.Context, client *lmgrpc.BackendClient) error {
+		calls++
+		cancel() // like a hedge that lost
+		return status.Error(codes.Unavailable, "connection reset")
+	})
+	if calls != 1 || time.Since(start) > time.Second {
+		t.Errorf("Expected a cancelled call to give up during backoff, %d calls in %v", calls, time.Since(start))
+	}
+}
+
+func TestRemoveNode_ClosesOnlyItsClient(t *testing.T) {
+	w, _, _ := newTestWorker(t)
+	reg := w.registry
+	old := reg.Add("127.0.0.1", 1)
+	if _, err := w.getClient(old); err != nil {
+		t.Fatal(err)
+	}
+
+	// Same address added again, as a nodes file reload might while old drains
+	readded := reg.Add("127.0.0.1", 1)
+	fresh, err := w.getClient(readded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.RemoveNode(old.ID, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.getClient(old); err == nil {
+		t.Errorf("Expected no client for a removed node")
+	}
+	if client, _ := w.getClient(readded); client != fresh {
+		t.Errorf("Expected the re-added node to keep its client")
+	}
+}